@@ -7,6 +7,7 @@ import (
 	"sql-service/configs"
 	"sql-service/internal/documents"
 	"sql-service/internal/fiels"
+	"sql-service/internal/items"
 	"sql-service/internal/product"
 	"sql-service/internal/sqlproxy"
 	"sql-service/pkg/db"
@@ -25,11 +26,13 @@ func App() http.Handler {
 	// repositories
 	productRepository := product.NewProductRepository(conn)
 	documentsRepository := documents.NewDocumentRepository(conn)
+	itemRepository := items.NewItemRepository(conn)
 	sqlRepo := sqlproxy.NewRepository()
 
 	// services
 	productService := product.NewProductService(productRepository)
 	documentService := documents.NewDocumentService(documentsRepository)
+	itemService := items.NewItemService(itemRepository)
 	filesService := fiels.NewFilesService()
 	sqlSvc := sqlproxy.NewService(sqlRepo)
 
@@ -44,6 +47,11 @@ func App() http.Handler {
 		DocumentService: documentService,
 	})
 
+	items.NewItemController(router, items.ItemControllerDeps{
+		Config:      conf,
+		ItemService: itemService,
+	})
+
 	fiels.NewFielsController(router, fiels.FielsControllerDeps{
 		Config:      conf,
 		FileService: filesService,
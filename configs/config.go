@@ -13,6 +13,15 @@ type Config struct {
 	DbConfig            DbConfig
 	ImagesPath          string
 	ProductLineArtsPath string
+	AttachmentsPath     string
+	StatementPdf        StatementPdfConfig
+}
+
+// StatementPdfConfig drives the customer statement PDF, the logo is looked up in ImagesPath.
+type StatementPdfConfig struct {
+	FontPath    string
+	LogoFile    string
+	CompanyName string
 }
 
 type DbConfig struct {
@@ -43,6 +52,21 @@ func LoadConfig() *Config {
 		dialect = "mssql"
 	}
 
+	fontPath := strings.TrimSpace(os.Getenv("PDF_FONT_PATH"))
+	if fontPath == "" {
+		fontPath = `C:\Windows\Fonts\arial.ttf`
+	}
+
+	attachmentsPath := strings.TrimSpace(os.Getenv("ATTACHMENTS_PATH"))
+	if attachmentsPath == "" {
+		attachmentsPath = `\\192.168.2.41\b1_shr\Attachments`
+	}
+
+	logoFile := strings.TrimSpace(os.Getenv("COMPANY_LOGO"))
+	if logoFile == "" {
+		logoFile = "logo.png"
+	}
+
 	return &Config{
 		DbConfig: DbConfig{
 			Dialect:  dialect,
@@ -55,5 +79,11 @@ func LoadConfig() *Config {
 		},
 		ImagesPath:          `\\192.168.2.41\b1_shr\Bitmaps\ProductImages`,
 		ProductLineArtsPath: `\\192.168.2.41\b1_shr\Bitmaps\Productlinearts`,
+		AttachmentsPath:     attachmentsPath,
+		StatementPdf: StatementPdfConfig{
+			FontPath:    fontPath,
+			LogoFile:    logoFile,
+			CompanyName: strings.TrimSpace(os.Getenv("COMPANY_NAME")),
+		},
 	}
 }
@@ -5,6 +5,7 @@ go 1.24.0
 require (
 	github.com/SAP/go-hdb v1.14.15
 	github.com/denisenkom/go-mssqldb v0.12.3
+	github.com/go-pdf/fpdf v0.9.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/joho/godotenv v1.5.1
 )
@@ -0,0 +1,143 @@
+package documents
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	agingBucketCurrent = "current"
+	agingBucket1To30   = "1-30"
+	agingBucket31To60  = "31-60"
+	agingBucket61To90  = "61-90"
+	agingBucketOver90  = "90+"
+	agingBucketCredits = "credits"
+)
+
+// buildAgingReport buckets the open amounts of today by days past due counted
+// to asOf, the bucket reference date; it is not a historical aging, payments
+// made after asOf are already applied. Lines without a due date age from their
+// document date, open credits are kept apart in their own bucket.
+func buildAgingReport(lines []HovotLine, asOf, today time.Time) AgingReport {
+	report := AgingReport{
+		AsOfDate:    asOf.Format("2006-01-02"),
+		BalanceDate: today.Format("2006-01-02"),
+		Customers:   make([]CustomerAging, 0),
+	}
+
+	index := make(map[string]int)
+	for _, line := range lines {
+		if line.DocDate != nil && dateOnly(*line.DocDate).After(asOf) {
+			continue
+		}
+
+		i, ok := index[line.CardCode]
+		if !ok {
+			i = len(report.Customers)
+			index[line.CardCode] = i
+			report.Customers = append(report.Customers, CustomerAging{
+				CardCode: line.CardCode,
+				CardName: line.CardName,
+				Lines:    make([]AgingLine, 0, 8),
+			})
+		}
+		customer := &report.Customers[i]
+
+		aged := ageLine(line.Hovot, asOf)
+		customer.Lines = append(customer.Lines, aged)
+		addToBucket(&customer.Buckets, aged.Bucket, aged.Amount)
+		customer.Total += aged.Amount
+		if aged.Overdue {
+			customer.OverdueAmount += aged.Amount
+		}
+	}
+
+	for i := range report.Customers {
+		customer := &report.Customers[i]
+		roundBuckets(&customer.Buckets)
+		customer.Total = roundAmount(customer.Total)
+		customer.OverdueAmount = roundAmount(customer.OverdueAmount)
+		customer.Overdue = customer.OverdueAmount > 0
+
+		report.Totals.Current += customer.Buckets.Current
+		report.Totals.Days1To30 += customer.Buckets.Days1To30
+		report.Totals.Days31To60 += customer.Buckets.Days31To60
+		report.Totals.Days61To90 += customer.Buckets.Days61To90
+		report.Totals.Over90 += customer.Buckets.Over90
+		report.Totals.Credits += customer.Buckets.Credits
+		report.Total += customer.Total
+		report.OverdueAmount += customer.OverdueAmount
+	}
+	roundBuckets(&report.Totals)
+	report.Total = roundAmount(report.Total)
+	report.OverdueAmount = roundAmount(report.OverdueAmount)
+
+	return report
+}
+
+func ageLine(h Hovot, asOf time.Time) AgingLine {
+	due := h.DueDate
+	if due == nil {
+		due = h.DocDate
+	}
+
+	days := 0
+	if due != nil {
+		days = int(asOf.Sub(dateOnly(*due)).Hours() / 24)
+	}
+
+	line := AgingLine{Hovot: h, Bucket: agingBucketCurrent}
+	switch {
+	case h.Amount < 0:
+		// an unapplied credit is not overdue however old it is
+		line.Bucket = agingBucketCredits
+		days = max(days, 0)
+	case days <= 0:
+		days = 0
+	case days <= 30:
+		line.Bucket = agingBucket1To30
+	case days <= 60:
+		line.Bucket = agingBucket31To60
+	case days <= 90:
+		line.Bucket = agingBucket61To90
+	default:
+		line.Bucket = agingBucketOver90
+	}
+	line.DaysOverdue = days
+	line.Overdue = days > 0 && h.Amount > 0
+	return line
+}
+
+func addToBucket(b *AgingBuckets, bucket string, amount float64) {
+	switch bucket {
+	case agingBucket1To30:
+		b.Days1To30 += amount
+	case agingBucket31To60:
+		b.Days31To60 += amount
+	case agingBucket61To90:
+		b.Days61To90 += amount
+	case agingBucketOver90:
+		b.Over90 += amount
+	case agingBucketCredits:
+		b.Credits += amount
+	default:
+		b.Current += amount
+	}
+}
+
+func roundBuckets(b *AgingBuckets) {
+	b.Current = roundAmount(b.Current)
+	b.Days1To30 = roundAmount(b.Days1To30)
+	b.Days31To60 = roundAmount(b.Days31To60)
+	b.Days61To90 = roundAmount(b.Days61To90)
+	b.Over90 = roundAmount(b.Over90)
+	b.Credits = roundAmount(b.Credits)
+}
+
+func roundAmount(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func dateOnly(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
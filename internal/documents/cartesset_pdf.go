@@ -0,0 +1,303 @@
+package documents
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-pdf/fpdf"
+)
+
+type statementPdfOptions struct {
+	FontPath    string
+	LogoPath    string
+	CompanyName string
+	DateFrom    string
+	DateTo      string
+}
+
+type statementColumn struct {
+	title string
+	width float64
+	align string
+	value func(c Cartesset) string
+}
+
+const (
+	statementFont       = "statement"
+	statementPageWidth  = 210.0
+	statementMargin     = 10.0
+	statementRowHeight  = 6.0
+	statementPageBottom = 297.0 - 18
+	statementOpeningRow = "יתרת פתיחה"
+)
+
+// columns are listed right to left, the order a Hebrew reader scans them
+var statementColumns = []statementColumn{
+	{title: "תאריך", width: 20, align: "R", value: func(c Cartesset) string { return formatStatementDate(c.DocDate) }},
+	{title: "תאריך פירעון", width: 20, align: "R", value: func(c Cartesset) string { return formatStatementDate(c.DueDate) }},
+	{title: "סוג מסמך", width: 26, align: "R", value: func(c Cartesset) string { return c.DocType }},
+	{title: "מספר מסמך", width: 20, align: "R", value: func(c Cartesset) string { return derefString(c.DocNum) }},
+	{title: "אסמכתא", width: 24, align: "R", value: func(c Cartesset) string { return derefString(c.NumAtCard) }},
+	{title: "מספר הקצאה", width: 20, align: "R", value: func(c Cartesset) string { return derefString(c.ConfNum) }},
+	{title: "חובה", width: 20, align: "L", value: func(c Cartesset) string { return formatStatementAmount(c.Hova) }},
+	{title: "זכות", width: 20, align: "L", value: func(c Cartesset) string { return formatStatementAmount(c.Zchut) }},
+	{title: "יתרה", width: 20, align: "L", value: func(c Cartesset) string { return formatStatementAmount(c.RunningBalance) }},
+}
+
+// renderCartessetPDF writes a customer statement: header from OCRD, the opening
+// balance row, every movement with its running balance and a totals row.
+func renderCartessetPDF(w io.Writer, header *CustomerHeader, rows []Cartesset, opts statementPdfOptions) error {
+	font, err := os.ReadFile(opts.FontPath)
+	if err != nil {
+		return fmt.Errorf("statement font not available: %w", err)
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(statementMargin, statementMargin, statementMargin)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddUTF8FontFromBytes(statementFont, "", font)
+	pdf.AliasNbPages("")
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont(statementFont, "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("%d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	writeStatementHeader(pdf, header, opts)
+	writeStatementTableHeader(pdf)
+
+	var totalHova, totalZchut, balance float64
+	pdf.SetFont(statementFont, "", 8)
+	for i, row := range rows {
+		ensureStatementSpace(pdf)
+		pdf.SetFont(statementFont, "", 8)
+
+		fill := row.DocType == statementOpeningRow
+		if fill {
+			pdf.SetFillColor(235, 235, 235)
+		} else if i%2 == 0 {
+			pdf.SetFillColor(250, 250, 250)
+			fill = true
+		}
+		writeStatementRow(pdf, func(col statementColumn) string { return col.value(row) }, fill)
+
+		totalHova += row.Hova
+		totalZchut += row.Zchut
+		balance = row.RunningBalance
+	}
+
+	ensureStatementSpace(pdf)
+	pdf.SetFont(statementFont, "", 9)
+	pdf.SetFillColor(220, 220, 220)
+	totals := map[string]string{
+		"סוג מסמך": "סה\"כ",
+		"חובה":     formatStatementAmount(roundAmount(totalHova)),
+		"זכות":     formatStatementAmount(roundAmount(totalZchut)),
+		"יתרה":     formatStatementAmount(balance),
+	}
+	writeStatementRow(pdf, func(col statementColumn) string { return totals[col.title] }, true)
+
+	if pdf.Err() {
+		return pdf.Error()
+	}
+	return pdf.Output(w)
+}
+
+func writeStatementHeader(pdf *fpdf.Fpdf, header *CustomerHeader, opts statementPdfOptions) {
+	top := pdf.GetY()
+	if opts.LogoPath != "" {
+		if _, err := os.Stat(opts.LogoPath); err == nil {
+			pdf.ImageOptions(opts.LogoPath, statementMargin, top, 0, 18, false, fpdf.ImageOptions{ReadDpi: true}, 0, "")
+		}
+	}
+
+	pdf.SetFont(statementFont, "", 14)
+	if opts.CompanyName != "" {
+		writeRTLLine(pdf, opts.CompanyName, 7)
+	}
+	writeRTLLine(pdf, "כרטסת לקוח", 7)
+
+	pdf.SetFont(statementFont, "", 9)
+	if opts.DateFrom != "" || opts.DateTo != "" {
+		writeRTLLine(pdf, fmt.Sprintf("לתקופה: %s - %s", opts.DateFrom, opts.DateTo), 5)
+	}
+	writeRTLLine(pdf, "תאריך הפקה: "+time.Now().Format("02/01/2006"), 5)
+
+	if header != nil {
+		pdf.Ln(2)
+		writeRTLLine(pdf, fmt.Sprintf("לקוח: %s %s", header.CardCode, derefString(header.CardName)), 5)
+		address := strings.TrimSpace(strings.Join(nonEmpty(derefString(header.Address), derefString(header.City), derefString(header.ZipCode)), ", "))
+		if address != "" {
+			writeRTLLine(pdf, "כתובת: "+address, 5)
+		}
+		if phone := derefString(header.Phone1); phone != "" {
+			writeRTLLine(pdf, "טלפון: "+phone, 5)
+		}
+		if email := derefString(header.Email); email != "" {
+			writeRTLLine(pdf, "דוא\"ל: "+email, 5)
+		}
+		if licTradNum := derefString(header.LicTradNum); licTradNum != "" {
+			writeRTLLine(pdf, "ח.פ/ע.מ: "+licTradNum, 5)
+		}
+	}
+
+	if pdf.GetY() < top+20 {
+		pdf.SetY(top + 20)
+	}
+	pdf.Ln(3)
+}
+
+func writeStatementTableHeader(pdf *fpdf.Fpdf) {
+	pdf.SetFont(statementFont, "", 8)
+	pdf.SetFillColor(200, 200, 200)
+	writeStatementRow(pdf, func(col statementColumn) string { return col.title }, true)
+}
+
+// ensureStatementSpace starts a new page, repeating the column titles, when the next row does not fit
+func ensureStatementSpace(pdf *fpdf.Fpdf) {
+	if pdf.GetY()+statementRowHeight <= statementPageBottom {
+		return
+	}
+	pdf.AddPage()
+	writeStatementTableHeader(pdf)
+}
+
+// writeStatementRow lays cells out from the right margin towards the left
+func writeStatementRow(pdf *fpdf.Fpdf, text func(col statementColumn) string, fill bool) {
+	y := pdf.GetY()
+	x := statementPageWidth - statementMargin
+	for _, col := range statementColumns {
+		x -= col.width
+		pdf.SetXY(x, y)
+		pdf.CellFormat(col.width, statementRowHeight, visualRTL(text(col)), "1", 0, col.align, fill, 0, "")
+	}
+	pdf.SetXY(statementMargin, y+statementRowHeight)
+}
+
+func writeRTLLine(pdf *fpdf.Fpdf, text string, height float64) {
+	pdf.CellFormat(0, height, visualRTL(text), "", 1, "R", false, 0, "")
+}
+
+// visualRTL converts a logical-order string into the visual order fpdf draws
+// left to right: runs of Latin letters and digits keep their direction, the
+// rest of the line is mirrored.
+func visualRTL(s string) string {
+	runes := []rune(s)
+	hasRTL := false
+	for _, r := range runes {
+		if unicode.Is(unicode.Hebrew, r) {
+			hasRTL = true
+			break
+		}
+	}
+	if !hasRTL {
+		return s
+	}
+
+	type run struct {
+		text []rune
+		ltr  bool
+	}
+	var runs []run
+	for i := 0; i < len(runes); {
+		j := i
+		if isLTRRune(runes[i]) {
+			for j < len(runes) && (isLTRRune(runes[j]) || (isLTRJoiner(runes[j]) && j+1 < len(runes) && isLTRRune(runes[j+1]))) {
+				j++
+			}
+			runs = append(runs, run{text: runes[i:j], ltr: true})
+		} else {
+			for j < len(runes) && !isLTRRune(runes[j]) {
+				j++
+			}
+			runs = append(runs, run{text: runes[i:j]})
+		}
+		i = j
+	}
+
+	out := make([]rune, 0, len(runes))
+	for i := len(runs) - 1; i >= 0; i-- {
+		if runs[i].ltr {
+			out = append(out, runs[i].text...)
+			continue
+		}
+		for k := len(runs[i].text) - 1; k >= 0; k-- {
+			out = append(out, mirrorRune(runs[i].text[k]))
+		}
+	}
+	return string(out)
+}
+
+func isLTRRune(r rune) bool {
+	return r < 0x80 && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+func isLTRJoiner(r rune) bool {
+	switch r {
+	case '.', ',', ':', '/', '-', '@', '_':
+		return true
+	}
+	return false
+}
+
+func mirrorRune(r rune) rune {
+	switch r {
+	case '(':
+		return ')'
+	case ')':
+		return '('
+	case '[':
+		return ']'
+	case ']':
+		return '['
+	}
+	return r
+}
+
+func formatStatementDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("02/01/2006")
+}
+
+func formatStatementAmount(v float64) string {
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	whole := fmt.Sprintf("%.2f", v)
+	intPart, frac := whole[:len(whole)-3], whole[len(whole)-3:]
+
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteRune(',')
+		}
+		b.WriteRune(r)
+	}
+	return sign + b.String() + frac
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return strings.TrimSpace(*s)
+}
+
+func nonEmpty(values ...string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
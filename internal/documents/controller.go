@@ -1,8 +1,14 @@
 package documents
 
 import (
+	"mime"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
 	"sql-service/configs"
+	"sql-service/pkg/apperr"
 	"sql-service/pkg/req"
 	"sql-service/pkg/res"
 )
@@ -26,7 +32,13 @@ func NewDocumentController(router *http.ServeMux, deps DocumentControllerDeps) *
 	router.Handle("POST /cartesset", controller.GetCartesset())
 	router.Handle("POST /openProducts", controller.OpenProducts())
 	router.Handle("POST /hovot", controller.GetHovot())
+	router.Handle("POST /hovot/aging", controller.GetAging())
 	router.Handle("GET /api/sap/documents", controller.GetSapDocuments())
+	router.Handle("GET /api/sap/documents/summary", controller.GetSapDocumentsSummary())
+	router.Handle("GET /api/sap/documents/{docType}/{docEntry}", controller.GetSapDocument())
+	router.Handle("GET /api/sap/documents/{docType}/{docEntry}/chain", controller.GetSapDocumentChain())
+	router.Handle("GET /api/sap/documents/{docType}/{docEntry}/attachments", controller.GetSapDocumentAttachments())
+	router.Handle("GET /api/sap/documents/{docType}/{docEntry}/attachments/{line}", controller.DownloadSapDocumentAttachment())
 
 	return controller
 }
@@ -39,36 +51,80 @@ func (Controller *DocumentController) GetCartesset() http.HandlerFunc {
 		}
 
 		if body.CardCode == "" || body.DateFrom == "" || body.DateTo == "" {
-			res.Json(w, map[string]any{
-				"error":    "cardCode, dateFrom, dateTo are required",
-				"required": []string{"cardCode", "dateFrom", "dateTo"},
-			}, http.StatusBadRequest)
+			res.Error(w, apperr.Validation("cardCode, dateFrom, dateTo are required", "required: cardCode, dateFrom, dateTo"))
+			return
+		}
+
+		if acceptsPDF(r) {
+			Controller.writeCartessetPDF(w, r, body)
+			return
+		}
+
+		data, err := Controller.DocumentService.DocumentServiceHandler(r.Context(), body)
+		if err != nil {
+			res.Error(w, err)
 			return
 		}
 
-		data := Controller.DocumentService.DocumentServiceHandler(body)
 		res.Json(w, data, http.StatusOK)
 	}
 }
 
+func (Controller *DocumentController) writeCartessetPDF(w http.ResponseWriter, r *http.Request, body *CartessetDto) {
+	opts := statementPdfOptions{
+		FontPath:    Controller.Config.StatementPdf.FontPath,
+		CompanyName: Controller.Config.StatementPdf.CompanyName,
+	}
+	if logo := Controller.Config.StatementPdf.LogoFile; logo != "" {
+		opts.LogoPath = filepath.Join(Controller.Config.ImagesPath, filepath.Base(logo))
+	}
+
+	pdf, err := Controller.DocumentService.CartessetPDF(r.Context(), body, opts)
+	if err != nil {
+		res.Error(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": "cartesset-" + filepath.Base(body.CardCode) + ".pdf"}))
+	w.WriteHeader(http.StatusOK)
+	w.Write(pdf)
+}
+
+func acceptsPDF(r *http.Request) bool {
+	return strings.Contains(strings.ToLower(r.Header.Get("Accept")), "application/pdf")
+}
+
 func (Controller *DocumentController) OpenProducts() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
 		body, err := req.HandleBody[AllProductsDto](&w, r)
 		if err != nil {
-			res.Json(w, map[string]any{"error": "invalid JSON body", "details": err.Error()}, http.StatusBadRequest)
 			return
 		}
 
-		if body == nil || body.UserExtId == "" {
-			res.Json(w, map[string]any{"error": "userExtId is required"}, http.StatusBadRequest)
+		if body.UserExtId == "" {
+			res.Error(w, apperr.Invalid("userExtId is required"))
 			return
 		}
 
-		data := Controller.DocumentService.OpenProducts(body)
+		for name, value := range map[string]string{"dateFrom": body.DateFrom, "dateTo": body.DateTo} {
+			if value == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				res.Error(w, apperr.Validation("invalid "+name, err.Error()))
+				return
+			}
+		}
+		if body.DateFrom != "" && body.DateTo != "" && body.DateFrom > body.DateTo {
+			res.Error(w, apperr.Invalid("dateFrom must be before or equal to dateTo"))
+			return
+		}
 
-		if data == nil {
-			data = []OpenProducts{}
+		data, err := Controller.DocumentService.OpenProducts(r.Context(), body)
+		if err != nil {
+			res.Error(w, err)
+			return
 		}
 
 		res.Json(w, data, http.StatusOK)
@@ -79,21 +135,51 @@ func (Controller *DocumentController) GetHovot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		body, err := req.HandleBody[HovotDto](&w, r)
 		if err != nil {
-			res.Json(w, map[string]any{"error": "invalid JSON body", "details": err.Error()}, http.StatusBadRequest)
 			return
 		}
 
-		if body == nil || body.CardCode == "" {
-			res.Json(w, map[string]any{"error": "cardCode is required"}, http.StatusBadRequest)
+		if body.CardCode == "" {
+			res.Error(w, apperr.Invalid("cardCode is required"))
 			return
 		}
 
-		data := Controller.DocumentService.Hovot(body)
-
-		if data == nil {
-			data = []Hovot{}
+		data, err := Controller.DocumentService.Hovot(r.Context(), body)
+		if err != nil {
+			res.Error(w, err)
+			return
 		}
 
 		res.Json(w, data, http.StatusOK)
 	}
 }
+
+func (Controller *DocumentController) GetAging() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := req.HandleBody[AgingDto](&w, r)
+		if err != nil {
+			return
+		}
+
+		if (body.CardCode == "") == (body.SlpCode == nil) {
+			res.Error(w, apperr.Invalid("exactly one of cardCode or slpCode is required"))
+			return
+		}
+
+		asOf := time.Now().UTC()
+		if body.AsOfDate != "" {
+			asOf, err = time.Parse("2006-01-02", body.AsOfDate)
+			if err != nil {
+				res.Error(w, apperr.Validation("invalid asOfDate", err.Error()))
+				return
+			}
+		}
+
+		report, err := Controller.DocumentService.Aging(r.Context(), body, dateOnly(asOf))
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.Json(w, report, http.StatusOK)
+	}
+}
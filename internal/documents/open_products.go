@@ -0,0 +1,81 @@
+package documents
+
+import "strings"
+
+// groupOpenProducts folds the open order lines into one row per item: the
+// quantities are summed and every order is listed once, comma separated, in
+// order number order. The order numbers, NumAtCard, dates and statuses are
+// taken from the first line of each order so that the lists line up by
+// position; the free texts are listed once each.
+func groupOpenProducts(lines []OpenProducts) []OpenProducts {
+	type group struct {
+		row           OpenProducts
+		orders        map[string]bool
+		docNumbers    []string
+		numAtCard     []string
+		orderDocDates []string
+		lineDocDates  []string
+		availStatuses []string
+		freeTexts     distinctList
+	}
+
+	order := make([]string, 0)
+	groups := make(map[string]*group)
+	for _, line := range lines {
+		g, ok := groups[line.ItemCode]
+		if !ok {
+			g = &group{row: OpenProducts{ItemCode: line.ItemCode}, orders: make(map[string]bool)}
+			groups[line.ItemCode] = g
+			order = append(order, line.ItemCode)
+		}
+
+		g.row.TotalOpenQty += line.TotalOpenQty
+		g.freeTexts.add(line.FreeTexts)
+
+		docNumber := strings.TrimSpace(line.DocNumbers)
+		if g.orders[docNumber] {
+			continue
+		}
+		g.orders[docNumber] = true
+		g.docNumbers = append(g.docNumbers, docNumber)
+		g.numAtCard = append(g.numAtCard, strings.TrimSpace(line.NumAtCard))
+		g.orderDocDates = append(g.orderDocDates, strings.TrimSpace(line.OrderDocDates))
+		g.lineDocDates = append(g.lineDocDates, strings.TrimSpace(line.LineDocDates))
+		g.availStatuses = append(g.availStatuses, strings.TrimSpace(line.AvailStatuses))
+	}
+
+	out := make([]OpenProducts, 0, len(order))
+	for _, itemCode := range order {
+		g := groups[itemCode]
+		g.row.TotalOpenQty = roundAmount(g.row.TotalOpenQty)
+		g.row.DocNumbers = strings.Join(g.docNumbers, ", ")
+		g.row.NumAtCard = strings.Join(g.numAtCard, ", ")
+		g.row.OrderDocDates = strings.Join(g.orderDocDates, ", ")
+		g.row.LineDocDates = strings.Join(g.lineDocDates, ", ")
+		g.row.AvailStatuses = strings.Join(g.availStatuses, ", ")
+		g.row.FreeTexts = g.freeTexts.String()
+		out = append(out, g.row)
+	}
+	return out
+}
+
+type distinctList struct {
+	values []string
+	seen   map[string]bool
+}
+
+func (l *distinctList) add(value string) {
+	value = strings.TrimSpace(value)
+	if value == "" || l.seen[value] {
+		return
+	}
+	if l.seen == nil {
+		l.seen = make(map[string]bool)
+	}
+	l.seen[value] = true
+	l.values = append(l.values, value)
+}
+
+func (l *distinctList) String() string {
+	return strings.Join(l.values, ", ")
+}
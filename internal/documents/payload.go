@@ -3,7 +3,12 @@ package documents
 import "time"
 
 type AllProductsDto struct {
-	UserExtId string `json:"userExtId"`
+	UserExtId   string `json:"userExtId"`
+	ItemCode    string `json:"itemCode"`
+	DateFrom    string `json:"dateFrom"`
+	DateTo      string `json:"dateTo"`
+	AvailStatus string `json:"availStatus"`
+	Grouped     bool   `json:"grouped"`
 }
 
 type CartessetDto struct {
@@ -16,6 +21,15 @@ type HovotDto struct {
 	CardCode string `json:"cardCode"`
 }
 
+// AgingDto selects the customers to age. AsOfDate is the bucket reference
+// date the days past due are counted to, today when empty; the balances are
+// always the open amounts of today.
+type AgingDto struct {
+	CardCode string `json:"cardCode"`
+	SlpCode  *int   `json:"slpCode"`
+	AsOfDate string `json:"asOfDate"`
+}
+
 type Cartesset struct {
 	DocDate        *time.Time `json:"docDate"`
 	DueDate        *time.Time `json:"dueDate"`
@@ -28,6 +42,17 @@ type Cartesset struct {
 	RunningBalance float64    `json:"runningBalance"`
 }
 
+type CustomerHeader struct {
+	CardCode   string  `json:"cardCode"`
+	CardName   *string `json:"cardName"`
+	Address    *string `json:"address"`
+	City       *string `json:"city"`
+	ZipCode    *string `json:"zipCode"`
+	Phone1     *string `json:"phone1"`
+	Email      *string `json:"email"`
+	LicTradNum *string `json:"licTradNum"`
+}
+
 type Hovot struct {
 	DueDate     *time.Time `json:"dueDate"`
 	DocDate     *time.Time `json:"docDate"`
@@ -39,13 +64,57 @@ type Hovot struct {
 	RunningOpen float64    `json:"runningOpen"`
 }
 
+// HovotLine is a Hovot row together with the customer it belongs to
+type HovotLine struct {
+	CardCode string
+	CardName *string
+	Hovot
+}
+
+type AgingBuckets struct {
+	Current    float64 `json:"current"`
+	Days1To30  float64 `json:"days1To30"`
+	Days31To60 float64 `json:"days31To60"`
+	Days61To90 float64 `json:"days61To90"`
+	Over90     float64 `json:"over90"`
+	Credits    float64 `json:"credits"`
+}
+
+type AgingLine struct {
+	Hovot
+	DaysOverdue int    `json:"daysOverdue"`
+	Bucket      string `json:"bucket"`
+	Overdue     bool   `json:"overdue"`
+}
+
+type CustomerAging struct {
+	CardCode      string       `json:"cardCode"`
+	CardName      *string      `json:"cardName"`
+	Buckets       AgingBuckets `json:"buckets"`
+	Total         float64      `json:"total"`
+	OverdueAmount float64      `json:"overdueAmount"`
+	Overdue       bool         `json:"overdue"`
+	Lines         []AgingLine  `json:"lines"`
+}
+
+type AgingReport struct {
+	AsOfDate      string          `json:"asOfDate"`
+	BalanceDate   string          `json:"balanceDate"`
+	CardCode      string          `json:"cardCode,omitempty"`
+	SlpCode       *int            `json:"slpCode,omitempty"`
+	Totals        AgingBuckets    `json:"totals"`
+	Total         float64         `json:"total"`
+	OverdueAmount float64         `json:"overdueAmount"`
+	Customers     []CustomerAging `json:"customers"`
+}
+
 type OpenProducts struct {
-	ItemCode      string `json:"itemCode"`
-	TotalOpenQty  int    `json:"totalOpenQty"`
-	DocNumbers    string `json:"docNumbers"`
-	NumAtCard     string `json:"numAtCard"`
-	OrderDocDates string `json:"orderDocDates"`
-	LineDocDates  string `json:"lineDocDates"`
-	AvailStatuses string `json:"availStatuses"`
-	FreeTexts     string `json:"freeTexts"`
+	ItemCode      string  `json:"itemCode"`
+	TotalOpenQty  float64 `json:"totalOpenQty"`
+	DocNumbers    string  `json:"docNumbers"`
+	NumAtCard     string  `json:"numAtCard"`
+	OrderDocDates string  `json:"orderDocDates"`
+	LineDocDates  string  `json:"lineDocDates"`
+	AvailStatuses string  `json:"availStatuses"`
+	FreeTexts     string  `json:"freeTexts"`
 }
@@ -3,7 +3,10 @@ package documents
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
+	"sql-service/pkg/apperr"
 	"sql-service/pkg/db"
 )
 
@@ -15,8 +18,7 @@ func NewDocumentRepository(db *db.Db) *DocumentRrepository {
 	return &DocumentRrepository{Db: db}
 }
 
-func (r *DocumentRrepository) GetCartesset(dto *CartessetDto) ([]Cartesset, error) {
-	const query = `
+const cartessetQueryMSSQL = `
 ;WITH Lines AS
 (
     SELECT
@@ -149,16 +151,43 @@ ORDER BY
     LineId;
     `
 
-	ctx := context.Background()
+func buildCartessetQuery(dialect string, dto *CartessetDto) (sqlQuery, error) {
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+		return sqlQuery{
+			Query: cartessetQueryMSSQL,
+			Args: []any{
+				sql.Named("cardCode", dto.CardCode),
+				sql.Named("fromDate", dto.DateFrom),
+				sql.Named("toDate", dto.DateTo),
+			},
+		}, nil
+	case "hana":
+		return sqlQuery{
+			Query: cartessetQueryHANA,
+			Args: []any{
+				dto.CardCode,
+				dto.DateFrom,
+				dto.DateTo,
+				dto.CardCode,
+				dto.DateFrom,
+			},
+		}, nil
+	default:
+		return sqlQuery{}, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+}
 
-	rows, err := r.Db.QueryContext(ctx, query,
-		sql.Named("cardCode", dto.CardCode),
-		sql.Named("fromDate", dto.DateFrom),
-		sql.Named("toDate", dto.DateTo),
-	)
+func (r *DocumentRrepository) GetCartesset(ctx context.Context, dto *CartessetDto) ([]Cartesset, error) {
+	query, err := buildCartessetQuery(r.Db.Dialect, dto)
 	if err != nil {
 		return nil, err
 	}
+
+	rows, err := r.Db.QueryContext(ctx, query.Query, query.Args...)
+	if err != nil {
+		return nil, apperr.Upstream("cartesset query failed", err)
+	}
 	defer rows.Close()
 
 	var out []Cartesset
@@ -175,21 +204,59 @@ ORDER BY
 			&c.Zchut,
 			&c.RunningBalance,
 		); err != nil {
-			return nil, err
+			return nil, apperr.Upstream("cartesset query failed", err)
 		}
 		out = append(out, c)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, apperr.Upstream("cartesset query failed", err)
 	}
 	return out, nil
 }
 
-func (r *DocumentRrepository) GetHovot(dto *HovotDto) ([]Hovot, error) {
-	const query = `
+func (r *DocumentRrepository) GetCustomerHeader(ctx context.Context, cardCode string) (*CustomerHeader, error) {
+	var query sqlQuery
+	switch strings.ToLower(r.Db.Dialect) {
+	case "", "mssql":
+		query = sqlQuery{
+			Query: "SELECT CardCode, CardName, Address, City, ZipCode, Phone1, E_Mail, LicTradNum FROM OCRD WHERE CardCode = @cardCode",
+			Args:  []any{sql.Named("cardCode", cardCode)},
+		}
+	case "hana":
+		query = sqlQuery{
+			Query: "SELECT CardCode, CardName, Address, City, ZipCode, Phone1, E_Mail, LicTradNum FROM OCRD WHERE CardCode = ?",
+			Args:  []any{cardCode},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported db dialect: %s", r.Db.Dialect)
+	}
+
+	var h CustomerHeader
+	err := r.Db.QueryRowContext(ctx, query.Query, query.Args...).Scan(
+		&h.CardCode,
+		&h.CardName,
+		&h.Address,
+		&h.City,
+		&h.ZipCode,
+		&h.Phone1,
+		&h.Email,
+		&h.LicTradNum,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperr.Upstream("customer lookup failed", err)
+	}
+	return &h, nil
+}
+
+// hovotQueryMSSQL lists open debit/credit lines; %s is the JDT1 account predicate
+const hovotQueryMSSQL = `
 ;WITH J AS
 (
     SELECT
+        T1.ShortName AS CardCode,
         T0.RefDate AS DocDate,
         CAST(T0.DueDate AS date) AS DueDate,
         T0.TransId,
@@ -206,12 +273,13 @@ func (r *DocumentRrepository) GetHovot(dto *HovotDto) ([]Hovot, error) {
     FROM OJDT T0
     INNER JOIN JDT1 T1 ON T1.TransId = T0.TransId
     WHERE
-        T1.ShortName = @cardCode
+        %s
         AND (ISNULL(T1.BalDueDeb,0) <> 0 OR ISNULL(T1.BalDueCred,0) <> 0)
 ),
 DataWithDoc AS
 (
     SELECT
+        J.CardCode,
         J.DocDate,
         J.DueDate,
         J.DocType,
@@ -243,6 +311,7 @@ DataWithDoc AS
 FinalData AS
 (
     SELECT
+        D.CardCode,
         D.DocDate,
         D.DueDate,
         D.DocType,
@@ -251,39 +320,104 @@ FinalData AS
         D.ConfNum,
         D.Amount,
         CAST(
-            SUM(D.Amount) OVER (ORDER BY D.DueDate, D.DocDate, D.TransId, D.Line_ID ROWS UNBOUNDED PRECEDING)
+            SUM(D.Amount) OVER (PARTITION BY D.CardCode ORDER BY D.DueDate, D.DocDate, D.TransId, D.Line_ID ROWS UNBOUNDED PRECEDING)
         AS decimal(19,2)) AS RunningOpen,
         D.TransId,
         D.Line_ID AS LineId
     FROM DataWithDoc D
 )
 SELECT
-    DueDate,
-    DocDate,
-    DocType,
-    DocNum,
-    NumAtCard,
-    ConfNum,
-    Amount,
-    RunningOpen
-FROM FinalData
-ORDER BY DueDate, DocDate, TransId, LineId;
+    F.CardCode,
+    P.CardName,
+    F.DueDate,
+    F.DocDate,
+    F.DocType,
+    F.DocNum,
+    F.NumAtCard,
+    F.ConfNum,
+    F.Amount,
+    F.RunningOpen
+FROM FinalData F
+LEFT JOIN OCRD P ON P.CardCode = F.CardCode
+ORDER BY F.CardCode, F.DueDate, F.DocDate, F.TransId, F.LineId;
     `
 
-	ctx := context.Background()
+// hovotFilter selects the accounts whose open lines are returned, CardCode or SlpCode.
+type hovotFilter struct {
+	CardCode string
+	SlpCode  *int
+}
 
-	rows, err := r.Db.QueryContext(ctx, query,
-		sql.Named("cardCode", dto.CardCode),
-	)
+func buildHovotQuery(dialect string, filter hovotFilter) (sqlQuery, error) {
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+		if filter.SlpCode != nil {
+			return sqlQuery{
+				Query: fmt.Sprintf(hovotQueryMSSQL, "T1.ShortName IN (SELECT CardCode FROM OCRD WHERE SlpCode = @slpCode AND CardType = 'C')"),
+				Args:  []any{sql.Named("slpCode", *filter.SlpCode)},
+			}, nil
+		}
+		return sqlQuery{
+			Query: fmt.Sprintf(hovotQueryMSSQL, "T1.ShortName = @cardCode"),
+			Args:  []any{sql.Named("cardCode", filter.CardCode)},
+		}, nil
+	case "hana":
+		if filter.SlpCode != nil {
+			return sqlQuery{
+				Query: fmt.Sprintf(hovotQueryHANA, "T1.ShortName IN (SELECT CardCode FROM OCRD WHERE SlpCode = ? AND CardType = 'C')"),
+				Args:  []any{*filter.SlpCode},
+			}, nil
+		}
+		return sqlQuery{
+			Query: fmt.Sprintf(hovotQueryHANA, "T1.ShortName = ?"),
+			Args:  []any{filter.CardCode},
+		}, nil
+	default:
+		return sqlQuery{}, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+}
+
+func (r *DocumentRrepository) GetHovot(ctx context.Context, dto *HovotDto) ([]Hovot, error) {
+	lines, err := r.queryHovot(ctx, hovotFilter{CardCode: dto.CardCode})
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var out []Hovot
+	for _, line := range lines {
+		out = append(out, line.Hovot)
+	}
+	return out, nil
+}
+
+// GetHovotBySlpCode returns the open lines of every customer assigned to the sales employee.
+func (r *DocumentRrepository) GetHovotBySlpCode(ctx context.Context, slpCode int) ([]HovotLine, error) {
+	return r.queryHovot(ctx, hovotFilter{SlpCode: &slpCode})
+}
+
+// GetHovotByCardCode is GetHovot with the customer columns kept.
+func (r *DocumentRrepository) GetHovotByCardCode(ctx context.Context, cardCode string) ([]HovotLine, error) {
+	return r.queryHovot(ctx, hovotFilter{CardCode: cardCode})
+}
+
+func (r *DocumentRrepository) queryHovot(ctx context.Context, filter hovotFilter) ([]HovotLine, error) {
+	query, err := buildHovotQuery(r.Db.Dialect, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.QueryContext(ctx, query.Query, query.Args...)
+	if err != nil {
+		return nil, apperr.Upstream("hovot query failed", err)
+	}
+	defer rows.Close()
+
+	var out []HovotLine
 	for rows.Next() {
-		var h Hovot
+		var h HovotLine
 		if err := rows.Scan(
+			&h.CardCode,
+			&h.CardName,
 			&h.DueDate,
 			&h.DocDate,
 			&h.DocType,
@@ -293,18 +427,17 @@ ORDER BY DueDate, DocDate, TransId, LineId;
 			&h.Amount,
 			&h.RunningOpen,
 		); err != nil {
-			return nil, err
+			return nil, apperr.Upstream("hovot query failed", err)
 		}
 		out = append(out, h)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, apperr.Upstream("hovot query failed", err)
 	}
 	return out, nil
 }
 
-func (r *DocumentRrepository) GetOpenProducts(dto *AllProductsDto) ([]OpenProducts, error) {
-	const query = `
+const openProductsQueryMSSQL = `
 		SELECT
 			r.ItemCode,
 			r.OpenQty AS TotalOpenQty,
@@ -319,19 +452,57 @@ func (r *DocumentRrepository) GetOpenProducts(dto *AllProductsDto) ([]OpenProduc
 		WHERE r.LineStatus = 'O'
 		AND o.CANCELED = 'N'
 		AND o.CardCode = @cardCode
+		AND (@itemCode IS NULL OR r.ItemCode = @itemCode)
+		AND (@dateFrom IS NULL OR o.DocDate >= @dateFrom)
+		AND (@dateTo IS NULL OR o.DocDate <= @dateTo)
+		AND (@availStatus IS NULL OR r.U_AvailStat = @availStatus)
 		ORDER BY r.ItemCode, o.DocNum;
 	`
 
-	ctx := context.Background()
+func buildOpenProductsQuery(dialect string, dto *AllProductsDto) (sqlQuery, error) {
+	itemCode := optionalStringArg(&dto.ItemCode)
+	dateFrom := optionalStringArg(&dto.DateFrom)
+	dateTo := optionalStringArg(&dto.DateTo)
+	availStatus := optionalStringArg(&dto.AvailStatus)
 
-	rows, err := r.Db.QueryContext(
-		ctx,
-		query,
-		sql.Named("cardCode", dto.UserExtId),
-	)
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+		return sqlQuery{
+			Query: openProductsQueryMSSQL,
+			Args: []any{
+				sql.Named("cardCode", dto.UserExtId),
+				sql.Named("itemCode", itemCode),
+				sql.Named("dateFrom", dateFrom),
+				sql.Named("dateTo", dateTo),
+				sql.Named("availStatus", availStatus),
+			},
+		}, nil
+	case "hana":
+		return sqlQuery{
+			Query: openProductsQueryHANA,
+			Args: []any{
+				dto.UserExtId,
+				itemCode, itemCode,
+				dateFrom, dateFrom,
+				dateTo, dateTo,
+				availStatus, availStatus,
+			},
+		}, nil
+	default:
+		return sqlQuery{}, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+}
+
+func (r *DocumentRrepository) GetOpenProducts(ctx context.Context, dto *AllProductsDto) ([]OpenProducts, error) {
+	query, err := buildOpenProductsQuery(r.Db.Dialect, dto)
 	if err != nil {
 		return nil, err
 	}
+
+	rows, err := r.Db.QueryContext(ctx, query.Query, query.Args...)
+	if err != nil {
+		return nil, apperr.Upstream("open products query failed", err)
+	}
 	defer rows.Close()
 
 	out := make([]OpenProducts, 0, 64)
@@ -357,12 +528,12 @@ func (r *DocumentRrepository) GetOpenProducts(dto *AllProductsDto) ([]OpenProduc
 			&availStatuses,
 			&freeTexts,
 		); err != nil {
-			return nil, err
+			return nil, apperr.Upstream("open products query failed", err)
 		}
 
 		out = append(out, OpenProducts{
 			ItemCode:      itemCode,
-			TotalOpenQty:  int(totalOpenQty),
+			TotalOpenQty:  totalOpenQty,
 			DocNumbers:    docNumbers,
 			NumAtCard:     numAtCard,
 			OrderDocDates: orderDocDates,
@@ -373,7 +544,7 @@ func (r *DocumentRrepository) GetOpenProducts(dto *AllProductsDto) ([]OpenProduc
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, err
+		return nil, apperr.Upstream("open products query failed", err)
 	}
 
 	return out, nil
@@ -0,0 +1,251 @@
+package documents
+
+// HANA variants of the customer statement queries in repository.go.
+// They keep the same result columns and ordering as the T-SQL versions,
+// the positional arguments are bound in the matching build*Query function.
+
+const cartessetQueryHANA = `
+WITH Lines AS
+(
+    SELECT
+        T0.RefDate AS DocumentDate,
+        COALESCE(OINV.DocDueDate, ORIN.DocDueDate, TO_DATE(T0.DueDate)) AS DocDueDate,
+        T0.TransId,
+        T1.Line_ID,
+
+        CASE T0.TransType
+            WHEN 13 THEN N'חשבונית'
+            WHEN 14 THEN N'חשבונית זיכוי'
+            WHEN 24 THEN N'קבלה'
+            WHEN 30 THEN N'תנועת יומן'
+            ELSE N'תנועה'
+        END AS DocumentType,
+
+        COALESCE(
+            TO_NVARCHAR(OINV.DocNum),
+            TO_NVARCHAR(ORIN.DocNum),
+            TO_NVARCHAR(ORCT.DocNum),
+            NULLIF(T0.BaseRef, ''),
+            TO_NVARCHAR(T0.TransId)
+        ) AS DocumentNumber,
+
+        COALESCE(OINV.NumAtCard, ORIN.NumAtCard) AS NumAtCard,
+
+        RIGHT(
+            NULLIF(TRIM(COALESCE(OINV.U_INS_TDI_CONFNUM, ORIN.U_INS_TDI_CONFNUM)), ''),
+            9
+        ) AS ConfNum,
+
+        CAST(IFNULL(T1.Debit, 0)  AS DECIMAL(19,2)) AS Debit,
+        CAST(IFNULL(T1.Credit, 0) AS DECIMAL(19,2)) AS Credit,
+
+        CAST(IFNULL(T1.Debit, 0) - IFNULL(T1.Credit, 0) AS DECIMAL(19,2)) AS NetAmount,
+
+        CAST(
+            CASE
+                WHEN T0.TransType IN (13,14) THEN (IFNULL(T1.Debit,0) - IFNULL(T1.Credit,0))
+                WHEN T0.TransType = 30 AND (IFNULL(T1.Debit,0) - IFNULL(T1.Credit,0)) > 0
+                    THEN (IFNULL(T1.Debit,0) - IFNULL(T1.Credit,0))
+                ELSE 0
+            END
+        AS DECIMAL(19,2)) AS Hova,
+
+        CAST(
+            CASE
+                WHEN T0.TransType = 24 THEN (IFNULL(T1.Credit,0) - IFNULL(T1.Debit,0))
+                WHEN T0.TransType = 30 AND (IFNULL(T1.Credit,0) - IFNULL(T1.Debit,0)) > 0
+                    THEN (IFNULL(T1.Credit,0) - IFNULL(T1.Debit,0))
+                ELSE 0
+            END
+        AS DECIMAL(19,2)) AS Zchut
+    FROM OJDT T0
+    INNER JOIN JDT1 T1 ON T1.TransId = T0.TransId
+    LEFT JOIN OINV ON OINV.TransId = T0.TransId AND T0.TransType = 13
+    LEFT JOIN ORIN ON ORIN.TransId = T0.TransId AND T0.TransType = 14
+    LEFT JOIN ORCT ON ORCT.TransId = T0.TransId AND T0.TransType = 24
+    WHERE
+        T1.ShortName = ?
+        AND T0.RefDate >= ?
+        AND T0.RefDate <= ?
+        AND (IFNULL(T1.Debit,0) <> 0 OR IFNULL(T1.Credit,0) <> 0)
+),
+Opening AS
+(
+    SELECT
+        CAST(SUM(IFNULL(T1.Debit,0) - IFNULL(T1.Credit,0)) AS DECIMAL(19,2)) AS OpeningBalance
+    FROM OJDT T0
+    INNER JOIN JDT1 T1 ON T1.TransId = T0.TransId
+    WHERE
+        T1.ShortName = ?
+        AND T0.RefDate < ?
+        AND (IFNULL(T1.Debit,0) <> 0 OR IFNULL(T1.Credit,0) <> 0)
+),
+FinalData AS
+(
+    SELECT
+        0 AS SortRow,
+        CAST(NULL AS DATE) AS DocDate,
+        CAST(NULL AS DATE) AS DueDate,
+        N'יתרת פתיחה' AS DocType,
+        CAST(NULL AS NVARCHAR(30)) AS DocNum,
+        CAST(NULL AS NVARCHAR(100)) AS NumAtCard,
+        CAST(NULL AS NVARCHAR(9)) AS ConfNum,
+        CAST(0 AS DECIMAL(19,2)) AS Hova,
+        CAST(0 AS DECIMAL(19,2)) AS Zchut,
+        CAST(IFNULL(O.OpeningBalance,0) AS DECIMAL(19,2)) AS RunningBalance,
+        CAST(NULL AS INTEGER) AS TransId,
+        CAST(NULL AS INTEGER) AS LineId
+    FROM Opening O
+
+    UNION ALL
+
+    SELECT
+        1,
+        L.DocumentDate,
+        L.DocDueDate,
+        L.DocumentType,
+        L.DocumentNumber,
+        L.NumAtCard,
+        L.ConfNum,
+        L.Hova,
+        L.Zchut,
+        CAST(
+            IFNULL((SELECT OpeningBalance FROM Opening),0)
+            + SUM(L.NetAmount) OVER (ORDER BY L.DocumentDate, L.TransId, L.Line_ID ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)
+        AS DECIMAL(19,2)),
+        L.TransId,
+        L.Line_ID
+    FROM Lines L
+)
+
+SELECT
+    DocDate,
+    DueDate,
+    DocType,
+    DocNum,
+    NumAtCard,
+    ConfNum,
+    Hova,
+    Zchut,
+    RunningBalance
+FROM FinalData
+ORDER BY
+    SortRow,
+    DocDate,
+    DueDate,
+    TransId,
+    LineId
+    `
+
+// hovotQueryHANA lists open debit/credit lines; %s is the JDT1 account predicate
+const hovotQueryHANA = `
+WITH J AS
+(
+    SELECT
+        T1.ShortName AS CardCode,
+        T0.RefDate AS DocDate,
+        TO_DATE(T0.DueDate) AS DueDate,
+        T0.TransId,
+        T1.Line_ID,
+        T0.TransType,
+        CAST(IFNULL(T1.BalDueDeb,0) - IFNULL(T1.BalDueCred,0) AS DECIMAL(19,2)) AS Amount,
+        CASE T0.TransType
+            WHEN 13 THEN N'חשבונית'
+            WHEN 14 THEN N'חשבונית זיכוי'
+            WHEN 24 THEN N'קבלה'
+            WHEN 30 THEN N'תנועת יומן'
+            ELSE N'תנועה'
+        END AS DocType
+    FROM OJDT T0
+    INNER JOIN JDT1 T1 ON T1.TransId = T0.TransId
+    WHERE
+        %s
+        AND (IFNULL(T1.BalDueDeb,0) <> 0 OR IFNULL(T1.BalDueCred,0) <> 0)
+),
+DataWithDoc AS
+(
+    SELECT
+        J.CardCode,
+        J.DocDate,
+        J.DueDate,
+        J.DocType,
+        COALESCE(
+            CASE WHEN J.TransType = 13 THEN TO_NVARCHAR(I.DocNum) END,
+            CASE WHEN J.TransType = 14 THEN TO_NVARCHAR(C.DocNum) END,
+            TO_NVARCHAR(J.TransId)
+        ) AS DocNum,
+        COALESCE(
+            CASE WHEN J.TransType = 13 THEN I.NumAtCard END,
+            CASE WHEN J.TransType = 14 THEN C.NumAtCard END
+        ) AS NumAtCard,
+        RIGHT(
+            NULLIF(TRIM(
+                COALESCE(
+                    CASE WHEN J.TransType = 13 THEN I.U_INS_TDI_CONFNUM END,
+                    CASE WHEN J.TransType = 14 THEN C.U_INS_TDI_CONFNUM END
+                )
+            ), ''),
+            9
+        ) AS ConfNum,
+        J.Amount,
+        J.TransId,
+        J.Line_ID
+    FROM J
+    LEFT JOIN OINV I ON I.TransId = J.TransId AND J.TransType = 13
+    LEFT JOIN ORIN C ON C.TransId = J.TransId AND J.TransType = 14
+),
+FinalData AS
+(
+    SELECT
+        D.CardCode,
+        D.DocDate,
+        D.DueDate,
+        D.DocType,
+        D.DocNum,
+        D.NumAtCard,
+        D.ConfNum,
+        D.Amount,
+        CAST(
+            SUM(D.Amount) OVER (PARTITION BY D.CardCode ORDER BY D.DueDate, D.DocDate, D.TransId, D.Line_ID ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW)
+        AS DECIMAL(19,2)) AS RunningOpen,
+        D.TransId,
+        D.Line_ID AS LineId
+    FROM DataWithDoc D
+)
+SELECT
+    F.CardCode,
+    P.CardName,
+    F.DueDate,
+    F.DocDate,
+    F.DocType,
+    F.DocNum,
+    F.NumAtCard,
+    F.ConfNum,
+    F.Amount,
+    F.RunningOpen
+FROM FinalData F
+LEFT JOIN OCRD P ON P.CardCode = F.CardCode
+ORDER BY F.CardCode, F.DueDate, F.DocDate, F.TransId, F.LineId
+    `
+
+const openProductsQueryHANA = `
+		SELECT
+			r.ItemCode,
+			r.OpenQty AS TotalOpenQty,
+			TO_NVARCHAR(o.DocNum)                AS DocNumbers,
+			IFNULL(o.NumAtCard, '')              AS NumAtCard,
+			TO_NVARCHAR(o.DocDate, 'YYYY-MM-DD') AS OrderDocDates,
+			TO_NVARCHAR(r.DocDate, 'YYYY-MM-DD') AS LineDocDates,
+			IFNULL(r.U_AvailStat, '')            AS AvailStatuses,
+			IFNULL(r.FreeTxt, '')                AS FreeTexts
+		FROM RDR1 r
+		JOIN ORDR o ON o.DocEntry = r.DocEntry
+		WHERE r.LineStatus = 'O'
+		AND o.CANCELED = 'N'
+		AND o.CardCode = ?
+		AND (? IS NULL OR r.ItemCode = ?)
+		AND (? IS NULL OR o.DocDate >= ?)
+		AND (? IS NULL OR o.DocDate <= ?)
+		AND (? IS NULL OR r.U_AvailStat = ?)
+		ORDER BY r.ItemCode, o.DocNum
+	`
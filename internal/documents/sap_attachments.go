@@ -0,0 +1,108 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path"
+	"strings"
+
+	"sql-service/internal/fiels"
+	"sql-service/pkg/apperr"
+)
+
+// GetSapDocumentAttachments lists the ATC1 lines behind the document's AtcEntry.
+func (r *DocumentRrepository) GetSapDocumentAttachments(ctx context.Context, docType string, docEntry int64) ([]SapAttachment, error) {
+	tableDef, ok := sapDocTableByType[docType]
+	if !ok {
+		return nil, apperr.Validation("invalid docType", docTypeAllowedDetails())
+	}
+
+	headerQuery, err := bindSapQuery(r.Db.Dialect,
+		fmt.Sprintf("SELECT AtcEntry FROM %s WHERE DocEntry = {docEntry}", tableDef.Header),
+		map[string]any{"docEntry": docEntry},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var atcEntry sql.NullInt64
+	err = r.Db.QueryRowContext(ctx, headerQuery.Query, headerQuery.Args...).Scan(&atcEntry)
+	if err == sql.ErrNoRows {
+		return nil, apperr.NotFound("document not found")
+	}
+	if err != nil {
+		return nil, apperr.Upstream("failed to fetch attachments", err)
+	}
+
+	attachments := make([]SapAttachment, 0)
+	if !atcEntry.Valid {
+		return attachments, nil
+	}
+
+	linesQuery, err := bindSapQuery(r.Db.Dialect,
+		"SELECT Line, trgtPath, FileName, FileExt, Date FROM ATC1 WHERE AbsEntry = {atcEntry} ORDER BY Line",
+		map[string]any{"atcEntry": atcEntry.Int64},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.QueryContext(ctx, linesQuery.Query, linesQuery.Args...)
+	if err != nil {
+		return nil, apperr.Upstream("failed to fetch attachments", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			attachment SapAttachment
+			targetPath sql.NullString
+			fileName   sql.NullString
+			fileExt    sql.NullString
+			date       sql.NullTime
+		)
+		if err := rows.Scan(&attachment.Line, &targetPath, &fileName, &fileExt, &date); err != nil {
+			return nil, apperr.Upstream("failed to fetch attachments", err)
+		}
+
+		attachment.TargetPath = strings.TrimSpace(targetPath.String)
+		attachment.FileName = strings.TrimSpace(fileName.String)
+		if ext := strings.TrimSpace(fileExt.String); ext != "" {
+			attachment.FileName += "." + ext
+		}
+		if date.Valid {
+			attachment.Date = &date.Time
+		}
+		attachment.URL = fmt.Sprintf("/api/sap/documents/%s/%d/attachments/%d", docType, docEntry, attachment.Line)
+		attachments = append(attachments, attachment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("failed to fetch attachments", err)
+	}
+
+	return attachments, nil
+}
+
+// attachmentFilePath maps an attachment onto the configured root. trgtPath is
+// the folder as SAP sees it; when it sits under the root its subfolder is kept,
+// otherwise the file is expected directly in the root.
+func attachmentFilePath(root string, attachment SapAttachment) (string, error) {
+	// the file name is a single path element, only trgtPath may add folders
+	name := attachment.FileName
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\") {
+		return "", fiels.ErrUnsafePath
+	}
+
+	normalize := func(value string) string {
+		return strings.TrimRight(strings.ReplaceAll(value, "\\", "/"), "/")
+	}
+
+	subFolder := ""
+	target, base := normalize(attachment.TargetPath), normalize(root)
+	if base != "" && len(target) > len(base) && strings.EqualFold(target[:len(base)], base) && target[len(base)] == '/' {
+		subFolder = target[len(base)+1:]
+	}
+
+	return fiels.SafeJoin(root, path.Join(subFolder, name))
+}
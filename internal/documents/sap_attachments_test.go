@@ -0,0 +1,53 @@
+package documents
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"sql-service/internal/fiels"
+)
+
+func TestAttachmentFilePath(t *testing.T) {
+	root := `\\sapserver\B1_SHF\Attachments`
+
+	tests := []struct {
+		name       string
+		targetPath string
+		fileName   string
+		want       string
+	}{
+		{name: "file in the root", targetPath: `\\sapserver\B1_SHF\Attachments`, fileName: "scan.pdf", want: "scan.pdf"},
+		{name: "trailing separator", targetPath: `\\sapserver\B1_SHF\Attachments\`, fileName: "scan.pdf", want: "scan.pdf"},
+		{name: "sub folder is kept", targetPath: `\\sapserver\B1_SHF\Attachments\2024\Orders`, fileName: "scan.pdf", want: "2024/Orders/scan.pdf"},
+		{name: "root matches case-insensitively", targetPath: `\\SAPSERVER\b1_shf\attachments\2024`, fileName: "scan.pdf", want: "2024/scan.pdf"},
+		{name: "trgtPath outside the root", targetPath: `D:\Other\Attachments\2024`, fileName: "scan.pdf", want: "scan.pdf"},
+		{name: "sibling folder sharing the prefix", targetPath: `\\sapserver\B1_SHF\Attachments2\2024`, fileName: "scan.pdf", want: "scan.pdf"},
+		{name: "empty trgtPath", targetPath: "", fileName: "scan.pdf", want: "scan.pdf"},
+		{name: "empty FileName", targetPath: `\\sapserver\B1_SHF\Attachments\2024`, fileName: ""},
+		{name: "parent FileName", targetPath: `\\sapserver\B1_SHF\Attachments\2024`, fileName: ".."},
+		{name: "FileName with a parent path", targetPath: `\\sapserver\B1_SHF\Attachments`, fileName: "../secret.txt"},
+		{name: "FileName with backslashes", targetPath: `\\sapserver\B1_SHF\Attachments`, fileName: `..\..\secret.txt`},
+		{name: "absolute FileName", targetPath: `\\sapserver\B1_SHF\Attachments`, fileName: "/etc/passwd"},
+		{name: "drive letter FileName", targetPath: `\\sapserver\B1_SHF\Attachments`, fileName: "C:secret.txt"},
+		{name: "parent sub folder", targetPath: `\\sapserver\B1_SHF\Attachments\..\..\Windows`, fileName: "win.ini"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := attachmentFilePath(root, SapAttachment{TargetPath: tt.targetPath, FileName: tt.fileName})
+			if tt.want == "" {
+				if !errors.Is(err, fiels.ErrUnsafePath) {
+					t.Fatalf("attachmentFilePath = %q, %v, want ErrUnsafePath", got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("attachmentFilePath failed: %v", err)
+			}
+			if want := filepath.Join(root, filepath.FromSlash(tt.want)); got != want {
+				t.Errorf("attachmentFilePath = %q, want %q", got, want)
+			}
+		})
+	}
+}
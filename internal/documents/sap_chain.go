@@ -0,0 +1,216 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/db"
+)
+
+const (
+	sapChainRoot       = "root"
+	sapChainUpstream   = "upstream"
+	sapChainDownstream = "downstream"
+
+	// sapChainMaxDocuments stops a walk through an unusually wide chain,
+	// e.g. a blanket order copied into hundreds of deliveries. Each direction
+	// has its own budget so a wide downstream side still leaves the upstream
+	// one complete.
+	sapChainMaxDocuments = 500
+)
+
+// GetSapDocumentChain walks the base links (BaseType/BaseEntry) up and the
+// target links (TargetType/TrgetEntry) down from a document. Payments are
+// linked to the invoices they settle through RCT2. A direction stops after
+// sapChainMaxDocuments documents and the chain is then marked truncated.
+func (r *DocumentRrepository) GetSapDocumentChain(ctx context.Context, docType string, docEntry int64) (SapDocumentChain, error) {
+	tableDef, ok := sapDocTableByType[docType]
+	if !ok {
+		return SapDocumentChain{}, apperr.Validation("invalid docType", docTypeAllowedDetails())
+	}
+
+	root, found, err := r.loadSapChainDocument(ctx, tableDef, docEntry)
+	if err != nil {
+		return SapDocumentChain{}, err
+	}
+	if !found {
+		return SapDocumentChain{}, apperr.NotFound("document not found")
+	}
+	root.Direction = sapChainRoot
+
+	rootRef := SapDocumentRef{DocType: docType, DocEntry: docEntry}
+	chain := SapDocumentChain{
+		Root:      rootRef,
+		Documents: []SapChainDocument{root},
+		Links:     []SapChainLink{},
+	}
+	visited := map[SapDocumentRef]bool{rootRef: true}
+	linked := make(map[SapChainLink]bool)
+
+	for _, direction := range []string{sapChainUpstream, sapChainDownstream} {
+		type queued struct {
+			ref   SapDocumentRef
+			depth int
+		}
+		queue := []queued{{ref: rootRef}}
+		added := 0
+
+		for len(queue) > 0 {
+			current := queue[0]
+			queue = queue[1:]
+
+			refs, err := r.loadSapChainLinks(ctx, sapDocTableByType[current.ref.DocType], current.ref.DocEntry, direction)
+			if err != nil {
+				return SapDocumentChain{}, err
+			}
+
+			for _, ref := range refs {
+				// a document left out would leave its links dangling
+				if !visited[ref] && added >= sapChainMaxDocuments {
+					chain.Truncated = true
+					continue
+				}
+
+				link := SapChainLink{From: ref, To: current.ref}
+				if direction == sapChainDownstream {
+					link = SapChainLink{From: current.ref, To: ref}
+				}
+				if !linked[link] {
+					linked[link] = true
+					chain.Links = append(chain.Links, link)
+				}
+
+				if visited[ref] {
+					continue
+				}
+				visited[ref] = true
+
+				document, _, err := r.loadSapChainDocument(ctx, sapDocTableByType[ref.DocType], ref.DocEntry)
+				if err != nil {
+					return SapDocumentChain{}, err
+				}
+				document.Direction = direction
+				document.Depth = current.depth + 1
+				chain.Documents = append(chain.Documents, document)
+				added++
+				queue = append(queue, queued{ref: ref, depth: current.depth + 1})
+			}
+		}
+	}
+
+	return chain, nil
+}
+
+// loadSapChainDocument returns the document with only its reference set when
+// the header no longer exists.
+func (r *DocumentRrepository) loadSapChainDocument(ctx context.Context, tableDef sapDocTable, docEntry int64) (SapChainDocument, bool, error) {
+	document := SapChainDocument{DocType: tableDef.DocType, DocEntry: docEntry}
+
+	query, err := bindSapQuery(r.Db.Dialect,
+		fmt.Sprintf("SELECT DocNum, DocDate, CardCode, DocTotal FROM %s WHERE DocEntry = {docEntry}", tableDef.Header),
+		map[string]any{"docEntry": docEntry},
+	)
+	if err != nil {
+		return SapChainDocument{}, false, err
+	}
+
+	var (
+		docNum   sql.NullInt64
+		docDate  sql.NullTime
+		cardCode sql.NullString
+		docTotal sql.NullFloat64
+	)
+	err = r.Db.QueryRowContext(ctx, query.Query, query.Args...).Scan(&docNum, &docDate, &cardCode, &docTotal)
+	if err == sql.ErrNoRows {
+		return document, false, nil
+	}
+	if err != nil {
+		return SapChainDocument{}, false, apperr.Upstream("failed to fetch document chain", err)
+	}
+
+	if docNum.Valid {
+		document.DocNum = &docNum.Int64
+	}
+	if docDate.Valid {
+		document.DocDate = &docDate.Time
+	}
+	if cardCode.Valid {
+		document.CardCode = &cardCode.String
+	}
+	if docTotal.Valid {
+		document.DocTotal = &docTotal.Float64
+	}
+	return document, true, nil
+}
+
+// loadSapChainLinks returns the documents one step up or down from a document.
+// Links to object types we do not serve (journal entries, -1) are dropped.
+func (r *DocumentRrepository) loadSapChainLinks(ctx context.Context, tableDef sapDocTable, docEntry int64, direction string) ([]SapDocumentRef, error) {
+	var statements []string
+	switch {
+	case direction == sapChainUpstream && tableDef.HasItemLines:
+		statements = append(statements, fmt.Sprintf("SELECT DISTINCT BaseType, BaseEntry FROM %s WHERE DocEntry = {docEntry} AND BaseEntry IS NOT NULL AND BaseType > 0", tableDef.Lines))
+	case direction == sapChainUpstream:
+		statements = append(statements, fmt.Sprintf("SELECT DISTINCT CAST(InvType AS INT), DocEntry FROM %s WHERE %s = {docEntry}", tableDef.Lines, tableDef.lineKey()))
+	case tableDef.HasItemLines:
+		statements = append(statements, fmt.Sprintf("SELECT DISTINCT TargetType, TrgetEntry FROM %s WHERE DocEntry = {docEntry} AND TrgetEntry IS NOT NULL AND TargetType > 0", tableDef.Lines))
+	}
+
+	// A/R documents are settled by incoming payments, RCT2 points back at them
+	if direction == sapChainDownstream {
+		switch tableDef.DocType {
+		case "Invoices", "CreditNotes", "DownPayments":
+			payments := sapDocTableByType["IncomingPayments"]
+			statements = append(statements, fmt.Sprintf(
+				"SELECT DISTINCT %d, %s FROM %s WHERE DocEntry = {docEntry} AND CAST(InvType AS INT) = {objType}",
+				payments.ObjType, payments.lineKey(), payments.Lines,
+			))
+		}
+	}
+
+	refs := make([]SapDocumentRef, 0)
+	for _, statement := range statements {
+		query, err := bindSapQuery(r.Db.Dialect, statement, map[string]any{"docEntry": docEntry, "objType": tableDef.ObjType})
+		if err != nil {
+			return nil, err
+		}
+
+		rows, err := r.Db.QueryContext(ctx, query.Query, query.Args...)
+		if err != nil {
+			return nil, apperr.Upstream("failed to fetch document chain", err)
+		}
+
+		for rows.Next() {
+			var (
+				objType int
+				entry   int64
+			)
+			if err := rows.Scan(&objType, &entry); err != nil {
+				rows.Close()
+				return nil, apperr.Upstream("failed to fetch document chain", err)
+			}
+			linkedTable, ok := sapDocTableByObjType[objType]
+			if !ok {
+				continue
+			}
+			refs = append(refs, SapDocumentRef{DocType: linkedTable.DocType, DocEntry: entry})
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, apperr.Upstream("failed to fetch document chain", err)
+		}
+	}
+	return refs, nil
+}
+
+// bindSapQuery binds the {name} tokens of statement, see db.Bind.
+func bindSapQuery(dialect, statement string, values map[string]any) (sqlQuery, error) {
+	query, args, err := db.Bind(dialect, statement, values)
+	if err != nil {
+		return sqlQuery{}, err
+	}
+	return sqlQuery{Query: query, Args: args}, nil
+}
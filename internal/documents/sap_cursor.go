@@ -0,0 +1,135 @@
+package documents
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"sql-service/pkg/db"
+)
+
+const (
+	sapPagingOffset  = ""
+	sapPagingKeyset  = "keyset"
+	sapPagingChanges = "changes"
+)
+
+// SapDocumentsCursor is the position after the last returned document. In
+// keyset mode it is (DocDate, DocEntry) in the requested direction, in changes
+// mode it is (UpdateDate, UpdateTS, DocEntry) ascending.
+type SapDocumentsCursor struct {
+	Mode     string `json:"m"`
+	Dir      string `json:"s,omitempty"`
+	Date     string `json:"d"`
+	Time     int    `json:"t,omitempty"`
+	DocEntry int64  `json:"e"`
+}
+
+func encodeSapDocumentsCursor(cursor SapDocumentsCursor) string {
+	payload, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func decodeSapDocumentsCursor(token string) (*SapDocumentsCursor, error) {
+	payload, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is not valid base64")
+	}
+
+	var cursor SapDocumentsCursor
+	if err := json.Unmarshal(payload, &cursor); err != nil {
+		return nil, fmt.Errorf("cursor is malformed")
+	}
+	if cursor.Mode != sapPagingKeyset && cursor.Mode != sapPagingChanges {
+		return nil, fmt.Errorf("cursor mode is unknown")
+	}
+	if _, err := time.Parse("2006-01-02", cursor.Date); err != nil {
+		return nil, fmt.Errorf("cursor date is malformed")
+	}
+	if cursor.Mode == sapPagingKeyset && cursor.Dir != "asc" && cursor.Dir != "desc" {
+		return nil, fmt.Errorf("cursor direction is unknown")
+	}
+	return &cursor, nil
+}
+
+// buildSapDocumentsKeysetQuery returns the keys of the next page after
+// query.Cursor, or of the first page when there is no cursor yet. It always
+// targets a single docType.
+func buildSapDocumentsKeysetQuery(dialect string, query SapDocumentsQuery) (sqlQuery, error) {
+	if len(query.DocTypes) != 1 {
+		return sqlQuery{}, fmt.Errorf("keyset paging needs exactly one docType")
+	}
+	tableDef, ok := sapDocTableByType[query.DocTypes[0]]
+	if !ok {
+		return sqlQuery{}, fmt.Errorf("unsupported docType: %s", query.DocTypes[0])
+	}
+
+	var (
+		seek  string
+		order string
+	)
+	switch query.Paging {
+	case sapPagingKeyset:
+		direction, comparison := "DESC", "<"
+		if query.SortDir == "asc" {
+			direction, comparison = "ASC", ">"
+		}
+		seek = fmt.Sprintf("(H.DocDate %[1]s {date} OR (H.DocDate = {date} AND H.DocEntry %[1]s {entry}))", comparison)
+		order = fmt.Sprintf("H.DocDate %[1]s, H.DocEntry %[1]s", direction)
+	case sapPagingChanges:
+		seek = `(H.UpdateDate > {date} OR (H.UpdateDate = {date} AND (COALESCE(H.UpdateTS, 0) > {time}
+        OR (COALESCE(H.UpdateTS, 0) = {time} AND H.DocEntry > {entry}))))`
+		order = "H.UpdateDate ASC, COALESCE(H.UpdateTS, 0) ASC, H.DocEntry ASC"
+	default:
+		return sqlQuery{}, fmt.Errorf("unsupported paging mode: %s", query.Paging)
+	}
+
+	var seekArgs map[string]any
+	if query.Cursor != nil {
+		cursorDate, err := time.Parse("2006-01-02", query.Cursor.Date)
+		if err != nil {
+			return sqlQuery{}, fmt.Errorf("invalid cursor date: %w", err)
+		}
+		seekArgs = map[string]any{
+			"date":  cursorDate,
+			"time":  query.Cursor.Time,
+			"entry": query.Cursor.DocEntry,
+		}
+	}
+
+	const selectKeys = "SELECT '%s' AS docType, H.DocEntry, H.DocDate, H.UpdateDate, COALESCE(H.UpdateTS, 0) AS UpdateTS FROM %s H WHERE %s ORDER BY %s"
+
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+		whereClause := sapDocumentsWhereMSSQL(query, tableDef)
+		args := sapDocumentsArgsMSSQL(query)
+		if seekArgs != nil {
+			whereClause += "\n  AND " + strings.NewReplacer("{date}", "@cursorDate", "{time}", "@cursorTime", "{entry}", "@cursorEntry").Replace(seek)
+			args = append(args,
+				sql.Named("cursorDate", seekArgs["date"]),
+				sql.Named("cursorTime", seekArgs["time"]),
+				sql.Named("cursorEntry", seekArgs["entry"]),
+			)
+		}
+		return sqlQuery{
+			Query: fmt.Sprintf(selectKeys+" OFFSET 0 ROWS FETCH NEXT @pageSize ROWS ONLY", tableDef.DocType, tableDef.Header, whereClause, order),
+			Args:  append(args, sql.Named("pageSize", query.PageSize)),
+		}, nil
+	case "hana":
+		whereClause, args := sapDocumentsWhereHANA(query, tableDef)
+		if seekArgs != nil {
+			clause, clauseArgs := db.BindPositional(seek, seekArgs)
+			whereClause += "\n  AND " + clause
+			args = append(args, clauseArgs...)
+		}
+		return sqlQuery{
+			Query: fmt.Sprintf(selectKeys+" LIMIT ?", tableDef.DocType, tableDef.Header, whereClause, order),
+			Args:  append(args, query.PageSize),
+		}, nil
+	default:
+		return sqlQuery{}, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+}
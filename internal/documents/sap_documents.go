@@ -3,22 +3,99 @@ package documents
 import "time"
 
 type SapDocumentsQuery struct {
-	DocType       string
-	CardCode      *string
-	DateFrom      time.Time
-	DateTo        time.Time
-	WarehouseCode *string
+	DocTypes              []string
+	CardCode              *string
+	DateFrom              time.Time
+	DateTo                time.Time
+	WarehouseCode         *string
 	WarehouseCodeNotEqual *string
-	DocStatus     *string
-	SortBy        string
-	SortDir       string
-	Page          int
-	PageSize      int
+	DocStatus             *string
+	SlpCode               *int
+	Text                  *string
+	DocTotalMin           *float64
+	DocTotalMax           *float64
+	Currency              *string
+	Canceled              *bool
+	ItemCode              *string
+	Series                *int
+	UpdateDateFrom        *time.Time
+	UpdateDateTo          *time.Time
+	SortBy                string
+	SortDir               string
+	Page                  int
+	PageSize              int
+	IncludeLines          bool
+	FieldsPreset          string
+	Fields                []string
+	Paging                string
+	Cursor                *SapDocumentsCursor
 }
 
 type SapDocumentsResponse struct {
-	Page     int              `json:"page"`
-	PageSize int              `json:"pageSize"`
-	Total    int              `json:"total"`
-	Items    []map[string]any `json:"items"`
+	Page     int `json:"page"`
+	PageSize int `json:"pageSize"`
+	// Total and Totals are only counted in offset paging; cursor pages would
+	// rescan the whole range for every page.
+	Total      *int             `json:"total,omitempty"`
+	Totals     map[string]int   `json:"totals,omitempty"`
+	Items      []map[string]any `json:"items"`
+	NextCursor string           `json:"nextCursor,omitempty"`
+	Checkpoint string           `json:"checkpoint,omitempty"`
+}
+
+type SapDocumentRef struct {
+	DocType  string `json:"docType"`
+	DocEntry int64  `json:"docEntry"`
+}
+
+type SapChainDocument struct {
+	DocType   string     `json:"docType"`
+	DocEntry  int64      `json:"docEntry"`
+	DocNum    *int64     `json:"docNum"`
+	DocDate   *time.Time `json:"docDate"`
+	CardCode  *string    `json:"cardCode"`
+	DocTotal  *float64   `json:"docTotal"`
+	Direction string     `json:"direction"`
+	Depth     int        `json:"depth"`
+}
+
+// SapChainLink points from the base document to the document copied from it.
+type SapChainLink struct {
+	From SapDocumentRef `json:"from"`
+	To   SapDocumentRef `json:"to"`
+}
+
+type SapDocumentChain struct {
+	Root      SapDocumentRef     `json:"root"`
+	Documents []SapChainDocument `json:"documents"`
+	Links     []SapChainLink     `json:"links"`
+	// Truncated is set when a direction hit sapChainMaxDocuments.
+	Truncated bool `json:"truncated"`
+}
+
+type SapAttachment struct {
+	Line       int        `json:"line"`
+	FileName   string     `json:"fileName"`
+	Date       *time.Time `json:"date"`
+	URL        string     `json:"url"`
+	TargetPath string     `json:"-"`
+}
+
+type SapSummaryRow struct {
+	Key   string  `json:"key"`
+	Label *string `json:"label"`
+	// DocTotal is the gross header total of header level groups, LineTotal
+	// the net line total of line level groups; only one of them is set.
+	DocTotal  *float64 `json:"docTotal,omitempty"`
+	LineTotal *float64 `json:"lineTotal,omitempty"`
+	VatSum    float64  `json:"vatSum"`
+	Quantity  float64  `json:"quantity"`
+	Documents int      `json:"documents"`
+}
+
+type SapSummaryResponse struct {
+	GroupBy  string          `json:"groupBy"`
+	DocTypes []string        `json:"docTypes"`
+	Rows     []SapSummaryRow `json:"rows"`
+	Total    SapSummaryRow   `json:"total"`
 }
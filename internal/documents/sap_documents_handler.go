@@ -1,44 +1,31 @@
 package documents
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"sql-service/internal/fiels"
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/req"
 	"sql-service/pkg/res"
 )
 
-type requestError struct {
-	status  int
-	message string
-	details string
-}
-
-func (e requestError) Error() string { return e.message }
-
 func (Controller *DocumentController) GetSapDocuments() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query, err := parseSapDocumentsQuery(r)
 		if err != nil {
-			status := http.StatusBadRequest
-			message := "invalid request"
-			details := err.Error()
-			var reqErr requestError
-			if errors.As(err, &reqErr) {
-				status = reqErr.status
-				message = reqErr.message
-				details = reqErr.details
-			}
-			res.Json(w, map[string]any{"error": message, "details": details}, status)
+			res.Error(w, err)
 			return
 		}
 
 		response, err := Controller.DocumentService.GetSapDocuments(r.Context(), *query)
 		if err != nil {
-			res.Json(w, map[string]any{"error": "failed to fetch documents", "details": err.Error()}, http.StatusInternalServerError)
+			res.Error(w, err)
 			return
 		}
 
@@ -50,63 +37,197 @@ func (Controller *DocumentController) GetSapDocuments() http.HandlerFunc {
 	}
 }
 
+func (Controller *DocumentController) GetSapDocument() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		docType, docEntry, err := parseSapDocumentPath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		document, err := Controller.DocumentService.GetSapDocument(r.Context(), docType, docEntry)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.Json(w, document, http.StatusOK)
+	}
+}
+
+func (Controller *DocumentController) GetSapDocumentChain() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		docType, docEntry, err := parseSapDocumentPath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		chain, err := Controller.DocumentService.GetSapDocumentChain(r.Context(), docType, docEntry)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.Json(w, chain, http.StatusOK)
+	}
+}
+
+func (Controller *DocumentController) GetSapDocumentAttachments() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		docType, docEntry, err := parseSapDocumentPath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		attachments, err := Controller.DocumentService.GetSapDocumentAttachments(r.Context(), docType, docEntry)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.Json(w, map[string]any{"attachments": attachments}, http.StatusOK)
+	}
+}
+
+func (Controller *DocumentController) DownloadSapDocumentAttachment() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		docType, docEntry, err := parseSapDocumentPath(r)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		line, err := strconv.Atoi(r.PathValue("line"))
+		if err != nil || line < 0 {
+			res.Error(w, apperr.Validation("invalid line", "line must be a non-negative integer"))
+			return
+		}
+
+		attachment, err := Controller.DocumentService.GetSapDocumentAttachment(r.Context(), docType, docEntry, line)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		filePath, err := attachmentFilePath(Controller.Config.AttachmentsPath, attachment)
+		if err != nil {
+			res.Error(w, apperr.Validation("invalid attachment path", err.Error()))
+			return
+		}
+
+		if err := fiels.ServeFile(w, r, filePath, attachment.FileName); err != nil {
+			if os.IsNotExist(err) {
+				res.Error(w, apperr.NotFound("attachment file not found"))
+				return
+			}
+			res.Error(w, apperr.Internal("failed to read attachment", err))
+		}
+	}
+}
+
+func (Controller *DocumentController) GetSapDocumentsSummary() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+
+		groupBy := strings.TrimSpace(values.Get("groupBy"))
+		if _, ok := sapSummaryGroups[groupBy]; !ok {
+			res.Error(w, apperr.Validation("invalid groupBy", "groupBy must be one of day, week, month, customer, salesEmployee, itemGroup, warehouse"))
+			return
+		}
+
+		if strings.TrimSpace(values.Get("docType")) == "" && strings.TrimSpace(values.Get("DocType")) == "" {
+			values.Set("docType", "Invoices,CreditNotes")
+		}
+		query, err := parseSapDocumentsValues(values)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+		if query.Paging != sapPagingOffset {
+			res.Error(w, apperr.Validation("cursor paging is not supported", "summary does not take cursor or changedSince"))
+			return
+		}
+		for _, docType := range query.DocTypes {
+			if !sapDocTableByType[docType].HasItemLines {
+				res.Error(w, apperr.Validation("invalid docType", fmt.Sprintf("%s cannot be summarized", docType)))
+				return
+			}
+		}
+
+		summary, err := Controller.DocumentService.GetSapDocumentsSummary(r.Context(), *query, groupBy)
+		if err != nil {
+			res.Error(w, err)
+			return
+		}
+
+		res.Json(w, summary, http.StatusOK)
+	}
+}
+
+func parseSapDocumentPath(r *http.Request) (string, int64, error) {
+	docType, ok := normalizeSapDocType(r.PathValue("docType"))
+	if !ok {
+		return "", 0, apperr.Validation("invalid docType", docTypeAllowedDetails())
+	}
+
+	docEntry, err := strconv.ParseInt(r.PathValue("docEntry"), 10, 64)
+	if err != nil || docEntry < 1 {
+		return "", 0, apperr.Validation("invalid docEntry", "docEntry must be a positive integer")
+	}
+	return docType, docEntry, nil
+}
+
 func parseSapDocumentsQuery(r *http.Request) (*SapDocumentsQuery, error) {
-	values := r.URL.Query()
+	return parseSapDocumentsValues(r.URL.Query())
+}
 
+func parseSapDocumentsValues(values url.Values) (*SapDocumentsQuery, error) {
 	docTypeRaw := strings.TrimSpace(values.Get("docType"))
 	if docTypeRaw == "" {
 		docTypeRaw = strings.TrimSpace(values.Get("DocType"))
 	}
 	if docTypeRaw == "" {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "docType is required",
-			details: "docType is required",
-		}
+		return nil, apperr.Invalid("docType is required")
 	}
-	docType, ok := normalizeSapDocType(docTypeRaw)
+	docTypes, ok := parseSapDocTypes(docTypeRaw)
 	if !ok {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "invalid docType",
-			details: docTypeAllowedDetails(),
-		}
+		return nil, apperr.Validation("invalid docType", docTypeAllowedDetails()+", a comma-separated list of them or all")
+	}
+
+	paging, cursor, err := parseSapDocumentsPaging(values)
+	if err != nil {
+		return nil, err
+	}
+	if paging != sapPagingOffset && len(docTypes) != 1 {
+		return nil, apperr.Validation("cursor paging needs a single docType", "cursor and changedSince can only be used with one docType")
 	}
 
+	// a change feed may run without a DocDate range
 	dateFromStr := strings.TrimSpace(values.Get("dateFrom"))
 	dateToStr := strings.TrimSpace(values.Get("dateTo"))
-	if dateFromStr == "" || dateToStr == "" {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "dateFrom and dateTo are required",
-			details: "dateFrom and dateTo are required",
-		}
+	if paging != sapPagingChanges && (dateFromStr == "" || dateToStr == "") {
+		return nil, apperr.Invalid("dateFrom and dateTo are required")
 	}
 
-	dateFrom, err := time.Parse("2006-01-02", dateFromStr)
-	if err != nil {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "invalid dateFrom",
-			details: err.Error(),
+	var dateFrom, dateTo time.Time
+	if dateFromStr != "" {
+		dateFrom, err = time.Parse("2006-01-02", dateFromStr)
+		if err != nil {
+			return nil, apperr.Validation("invalid dateFrom", err.Error())
 		}
 	}
 
-	dateTo, err := time.Parse("2006-01-02", dateToStr)
-	if err != nil {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "invalid dateTo",
-			details: err.Error(),
+	if dateToStr != "" {
+		dateTo, err = time.Parse("2006-01-02", dateToStr)
+		if err != nil {
+			return nil, apperr.Validation("invalid dateTo", err.Error())
 		}
 	}
 
-	if dateFrom.After(dateTo) {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "dateFrom must be before or equal to dateTo",
-			details: "dateFrom must be before or equal to dateTo",
-		}
+	if !dateFrom.IsZero() && !dateTo.IsZero() && dateFrom.After(dateTo) {
+		return nil, apperr.Invalid("dateFrom must be before or equal to dateTo")
 	}
 
 	var cardCode *string
@@ -124,25 +245,75 @@ func parseSapDocumentsQuery(r *http.Request) (*SapDocumentsQuery, error) {
 		warehouseCodeNotEqual = &value
 	}
 	if warehouseCode != nil && warehouseCodeNotEqual != nil && strings.EqualFold(*warehouseCode, *warehouseCodeNotEqual) {
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "warehouseCode and warehouseCodeNotEqual cannot be the same",
-			details: "warehouseCode and warehouseCodeNotEqual cannot be the same",
-		}
+		return nil, apperr.Invalid("warehouseCode and warehouseCodeNotEqual cannot be the same")
 	}
 
 	var docStatus *string
 	if value := strings.TrimSpace(values.Get("DocStatus")); value != "" {
 		if value != "O" && value != "C" {
-			return nil, requestError{
-				status:  http.StatusBadRequest,
-				message: "DocStatus must be O or C",
-				details: "DocStatus must be O or C",
-			}
+			return nil, apperr.Invalid("DocStatus must be O or C")
 		}
 		docStatus = &value
 	}
 
+	slpCode, err := req.OptionalInt(values.Get("slpCode"), "slpCode")
+	if err != nil {
+		return nil, err
+	}
+
+	var text *string
+	if value := strings.TrimSpace(values.Get("q")); value != "" {
+		text = &value
+	}
+
+	docTotalMin, err := optionalFloatParam(values.Get("docTotalMin"), "docTotalMin")
+	if err != nil {
+		return nil, err
+	}
+	docTotalMax, err := optionalFloatParam(values.Get("docTotalMax"), "docTotalMax")
+	if err != nil {
+		return nil, err
+	}
+	if docTotalMin != nil && docTotalMax != nil && *docTotalMin > *docTotalMax {
+		return nil, apperr.Invalid("docTotalMin must be less than or equal to docTotalMax")
+	}
+
+	var currency *string
+	if value := strings.ToUpper(strings.TrimSpace(values.Get("currency"))); value != "" {
+		currency = &value
+	}
+
+	var canceled *bool
+	if value := strings.TrimSpace(values.Get("canceled")); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, apperr.Validation("invalid canceled", "canceled must be true or false")
+		}
+		canceled = &parsed
+	}
+
+	var itemCode *string
+	if value := strings.TrimSpace(values.Get("itemCode")); value != "" {
+		itemCode = &value
+	}
+
+	series, err := req.OptionalInt(values.Get("series"), "series")
+	if err != nil {
+		return nil, err
+	}
+
+	updateDateFrom, err := optionalDateParam(values.Get("updateDateFrom"), "updateDateFrom")
+	if err != nil {
+		return nil, err
+	}
+	updateDateTo, err := optionalDateParam(values.Get("updateDateTo"), "updateDateTo")
+	if err != nil {
+		return nil, err
+	}
+	if updateDateFrom != nil && updateDateTo != nil && updateDateFrom.After(*updateDateTo) {
+		return nil, apperr.Invalid("updateDateFrom must be before or equal to updateDateTo")
+	}
+
 	sortBy := strings.TrimSpace(values.Get("sortBy"))
 	if sortBy == "" {
 		sortBy = "DocDate"
@@ -153,11 +324,7 @@ func parseSapDocumentsQuery(r *http.Request) (*SapDocumentsQuery, error) {
 	case "docentry":
 		sortBy = "DocEntry"
 	default:
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "invalid sortBy",
-			details: "sortBy must be DocDate or DocEntry",
-		}
+		return nil, apperr.Validation("invalid sortBy", "sortBy must be DocDate or DocEntry")
 	}
 
 	sortDir := strings.TrimSpace(values.Get("sortDir"))
@@ -170,10 +337,15 @@ func parseSapDocumentsQuery(r *http.Request) (*SapDocumentsQuery, error) {
 	case "desc":
 		sortDir = "desc"
 	default:
-		return nil, requestError{
-			status:  http.StatusBadRequest,
-			message: "invalid sortDir",
-			details: "sortDir must be asc or desc",
+		return nil, apperr.Validation("invalid sortDir", "sortDir must be asc or desc")
+	}
+
+	if paging == sapPagingKeyset {
+		if sortBy != "DocDate" {
+			return nil, apperr.Validation("invalid sortBy", "cursor paging is ordered by DocDate")
+		}
+		if cursor != nil {
+			sortDir = cursor.Dir
 		}
 	}
 
@@ -181,11 +353,7 @@ func parseSapDocumentsQuery(r *http.Request) (*SapDocumentsQuery, error) {
 	if value := strings.TrimSpace(values.Get("page")); value != "" {
 		parsed, err := strconv.Atoi(value)
 		if err != nil || parsed < 1 {
-			return nil, requestError{
-				status:  http.StatusBadRequest,
-				message: "invalid page",
-				details: "page must be an integer >= 1",
-			}
+			return nil, apperr.Validation("invalid page", "page must be an integer >= 1")
 		}
 		page = parsed
 	}
@@ -194,30 +362,203 @@ func parseSapDocumentsQuery(r *http.Request) (*SapDocumentsQuery, error) {
 	if value := strings.TrimSpace(values.Get("pageSize")); value != "" {
 		parsed, err := strconv.Atoi(value)
 		if err != nil || parsed < 1 || parsed > 200 {
-			return nil, requestError{
-				status:  http.StatusBadRequest,
-				message: "invalid pageSize",
-				details: "pageSize must be an integer between 1 and 200",
-			}
+			return nil, apperr.Validation("invalid pageSize", "pageSize must be an integer between 1 and 200")
 		}
 		pageSize = parsed
 	}
 
+	includeLines := false
+	if value := strings.TrimSpace(values.Get("includeLines")); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, apperr.Validation("invalid includeLines", "includeLines must be true or false")
+		}
+		includeLines = parsed
+	}
+
+	fieldsPreset, fields, err := parseSapDocumentFields(values.Get("fields"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(docTypes) == 1 {
+		tableDef := sapDocTableByType[docTypes[0]]
+		if docStatus != nil && !tableDef.HasDocStatus {
+			return nil, apperr.Validation("DocStatus is not supported", fmt.Sprintf("%s has no DocStatus", tableDef.DocType))
+		}
+		if (warehouseCode != nil || warehouseCodeNotEqual != nil) && !tableDef.HasItemLines {
+			return nil, apperr.Validation("warehouse filters are not supported", fmt.Sprintf("%s lines have no warehouse", tableDef.DocType))
+		}
+		if itemCode != nil && !tableDef.HasItemLines {
+			return nil, apperr.Validation("itemCode is not supported", fmt.Sprintf("%s lines have no items", tableDef.DocType))
+		}
+		if slpCode != nil && !tableDef.HasSlpCode {
+			return nil, apperr.Validation("slpCode is not supported", fmt.Sprintf("%s has no sales employee", tableDef.DocType))
+		}
+	}
+
 	return &SapDocumentsQuery{
-		DocType:       docType,
-		CardCode:      cardCode,
-		DateFrom:      dateFrom,
-		DateTo:        dateTo,
-		WarehouseCode: warehouseCode,
+		DocTypes:              docTypes,
+		CardCode:              cardCode,
+		DateFrom:              dateFrom,
+		DateTo:                dateTo,
+		WarehouseCode:         warehouseCode,
 		WarehouseCodeNotEqual: warehouseCodeNotEqual,
-		DocStatus:     docStatus,
-		SortBy:        sortBy,
-		SortDir:       sortDir,
-		Page:          page,
-		PageSize:      pageSize,
+		DocStatus:             docStatus,
+		SlpCode:               slpCode,
+		Text:                  text,
+		DocTotalMin:           docTotalMin,
+		DocTotalMax:           docTotalMax,
+		Currency:              currency,
+		Canceled:              canceled,
+		ItemCode:              itemCode,
+		Series:                series,
+		UpdateDateFrom:        updateDateFrom,
+		UpdateDateTo:          updateDateTo,
+		SortBy:                sortBy,
+		SortDir:               sortDir,
+		Page:                  page,
+		PageSize:              pageSize,
+		IncludeLines:          includeLines,
+		FieldsPreset:          fieldsPreset,
+		Fields:                fields,
+		Paging:                paging,
+		Cursor:                cursor,
 	}, nil
 }
 
+// parseSapDocumentsPaging picks the paging mode: offset by default, keyset when
+// a cursor parameter is present (empty for the first page) and changes when
+// changedSince is set or the cursor came from a change feed.
+func parseSapDocumentsPaging(values url.Values) (string, *SapDocumentsCursor, error) {
+	changedSince := strings.TrimSpace(values.Get("changedSince"))
+	token := strings.TrimSpace(values.Get("cursor"))
+
+	if changedSince != "" {
+		if token != "" {
+			return "", nil, apperr.Validation("cursor and changedSince cannot be combined", "pass the checkpoint as cursor without changedSince")
+		}
+		since, err := parseChangedSince(changedSince)
+		if err != nil {
+			return "", nil, apperr.Validation("invalid changedSince", "changedSince must be YYYY-MM-DD or YYYY-MM-DDTHH:MM:SS")
+		}
+		return sapPagingChanges, &SapDocumentsCursor{
+			Mode: sapPagingChanges,
+			Date: since.Format("2006-01-02"),
+			Time: since.Hour()*10000 + since.Minute()*100 + since.Second(),
+		}, nil
+	}
+
+	if !values.Has("cursor") {
+		return sapPagingOffset, nil, nil
+	}
+	if token == "" {
+		return sapPagingKeyset, nil, nil
+	}
+
+	cursor, err := decodeSapDocumentsCursor(token)
+	if err != nil {
+		return "", nil, apperr.Validation("invalid cursor", err.Error())
+	}
+	return cursor.Mode, cursor, nil
+}
+
+func parseChangedSince(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05", time.RFC3339, "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unsupported time format: %s", value)
+}
+
+func optionalFloatParam(value, name string) (*float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, apperr.Validation("invalid "+name, name+" must be a number")
+	}
+	return &parsed, nil
+}
+
+func optionalDateParam(value, name string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, apperr.Validation("invalid "+name, err.Error())
+	}
+	return &parsed, nil
+}
+
+// parseSapDocumentFields accepts a preset name (summary, full) or a
+// comma-separated list of header columns.
+func parseSapDocumentFields(value string) (string, []string, error) {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(value) {
+	case "", sapFieldsFull:
+		return sapFieldsFull, nil, nil
+	case sapFieldsSummary:
+		return sapFieldsSummary, nil, nil
+	}
+
+	fields := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, part := range strings.Split(value, ",") {
+		field := strings.TrimSpace(part)
+		if field == "" {
+			continue
+		}
+		if !req.IsIdentifier(field) {
+			return "", nil, apperr.Validation("invalid fields", fmt.Sprintf("invalid field name: %s", field))
+		}
+		key := strings.ToLower(field)
+		if _, exists := seen[key]; exists {
+			continue
+		}
+		seen[key] = struct{}{}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return "", nil, apperr.Validation("invalid fields", "fields must be summary, full or a comma-separated list of columns")
+	}
+	return "", fields, nil
+}
+
+// parseSapDocTypes accepts a single docType, a comma-separated list or "all".
+func parseSapDocTypes(value string) ([]string, bool) {
+	if strings.EqualFold(strings.TrimSpace(value), "all") {
+		docTypes := make([]string, 0, len(sapDocTables))
+		for _, entry := range sapDocTables {
+			docTypes = append(docTypes, entry.DocType)
+		}
+		return docTypes, true
+	}
+
+	docTypes := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, part := range strings.Split(value, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		docType, ok := normalizeSapDocType(part)
+		if !ok {
+			return nil, false
+		}
+		if _, exists := seen[docType]; exists {
+			continue
+		}
+		seen[docType] = struct{}{}
+		docTypes = append(docTypes, docType)
+	}
+	return docTypes, len(docTypes) > 0
+}
+
 func normalizeSapDocType(value string) (string, bool) {
 	key := normalizeSapDocTypeKey(value)
 	if key == "" {
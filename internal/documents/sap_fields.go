@@ -0,0 +1,100 @@
+package documents
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sql-service/pkg/apperr"
+)
+
+const (
+	sapFieldsFull    = "full"
+	sapFieldsSummary = "summary"
+)
+
+// sapDocumentSummaryFields is the summary preset. Columns a table does not
+// have are skipped, incoming payments carry DocCurr instead of DocCur.
+var sapDocumentSummaryFields = []string{
+	"DocEntry",
+	"DocNum",
+	"DocDate",
+	"DocDueDate",
+	"CardCode",
+	"CardName",
+	"NumAtCard",
+	"DocStatus",
+	"CANCELED",
+	"DocCur",
+	"DocCurr",
+	"DocTotal",
+	"DocTotalFC",
+	"SlpCode",
+	"Comments",
+	"UpdateDate",
+}
+
+// resolveSapDocumentColumns maps every requested docType to the header columns
+// to select, nil meaning all of them. Explicit fields are checked against the
+// real table columns; a field none of the requested tables has is rejected.
+func (r *DocumentRrepository) resolveSapDocumentColumns(ctx context.Context, query SapDocumentsQuery) (map[string][]string, error) {
+	requested := query.Fields
+	strict := true
+	switch query.FieldsPreset {
+	case "", sapFieldsFull:
+		if len(requested) == 0 {
+			return nil, nil
+		}
+	case sapFieldsSummary:
+		requested = sapDocumentSummaryFields
+		strict = false
+	default:
+		return nil, apperr.Validation("invalid fields", fmt.Sprintf("unknown fields preset: %s", query.FieldsPreset))
+	}
+
+	known := make(map[string]bool, len(requested))
+	columnsByType := make(map[string][]string, len(query.DocTypes))
+	for _, docType := range query.DocTypes {
+		tableDef, ok := sapDocTableByType[docType]
+		if !ok {
+			return nil, fmt.Errorf("unsupported docType: %s", docType)
+		}
+
+		tableColumns, err := r.Db.TableColumns(ctx, tableDef.Header)
+		if err != nil {
+			return nil, apperr.Upstream("failed to read document columns", err)
+		}
+		lookup := make(map[string]string, len(tableColumns))
+		for _, column := range tableColumns {
+			lookup[strings.ToLower(column)] = column
+		}
+
+		selected := []string{lookup["docentry"]}
+		for _, field := range requested {
+			column, ok := lookup[strings.ToLower(field)]
+			if !ok {
+				continue
+			}
+			known[strings.ToLower(field)] = true
+			if strings.EqualFold(column, "DocEntry") {
+				continue
+			}
+			selected = append(selected, column)
+		}
+		columnsByType[docType] = selected
+	}
+
+	if strict {
+		unknown := make([]string, 0)
+		for _, field := range requested {
+			if !known[strings.ToLower(field)] {
+				unknown = append(unknown, field)
+			}
+		}
+		if len(unknown) > 0 {
+			return nil, apperr.Validation("invalid fields", fmt.Sprintf("unknown fields: %s", strings.Join(unknown, ", ")))
+		}
+	}
+
+	return columnsByType, nil
+}
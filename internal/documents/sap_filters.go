@@ -0,0 +1,147 @@
+package documents
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"sql-service/pkg/db"
+)
+
+// sapDocumentFilter is one condition of the documents search. Clause renders it
+// for a table with {p} standing for the parameter; a filter the table cannot
+// answer renders "{p} IS NULL" so that setting it excludes the table, and a
+// negative filter the table cannot answer renders nothing.
+type sapDocumentFilter struct {
+	Name   string
+	Value  any
+	Clause func(t sapDocTable) string
+}
+
+func sapDocumentFilters(query SapDocumentsQuery) []sapDocumentFilter {
+	var canceled any
+	if query.Canceled != nil {
+		canceled = "N"
+		if *query.Canceled {
+			canceled = "Y"
+		}
+	}
+
+	var text any
+	if query.Text != nil && *query.Text != "" {
+		text = "%" + db.EscapeLike(*query.Text) + "%"
+	}
+
+	return []sapDocumentFilter{
+		{Name: "dateFrom", Value: optionalDateArg(query.DateFrom), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.DocDate >= {p})"
+		}},
+		{Name: "dateTo", Value: optionalDateArg(query.DateTo), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.DocDate <= {p})"
+		}},
+		{Name: "cardCode", Value: optionalStringArg(query.CardCode), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.CardCode = {p})"
+		}},
+		{Name: "docStatus", Value: optionalStringArg(query.DocStatus), Clause: func(t sapDocTable) string {
+			if !t.HasDocStatus {
+				return "{p} IS NULL"
+			}
+			return "({p} IS NULL OR H.DocStatus = {p})"
+		}},
+		{Name: "warehouseCode", Value: optionalStringArg(query.WarehouseCode), Clause: func(t sapDocTable) string {
+			if !t.HasItemLines {
+				return "{p} IS NULL"
+			}
+			return fmt.Sprintf(`({p} IS NULL OR EXISTS (
+        SELECT 1 FROM %s L
+        WHERE L.DocEntry = H.DocEntry AND L.WhsCode = {p}
+      ))`, t.Lines)
+		}},
+		{Name: "warehouseCodeNotEqual", Value: optionalStringArg(query.WarehouseCodeNotEqual), Clause: func(t sapDocTable) string {
+			if !t.HasItemLines {
+				return ""
+			}
+			return fmt.Sprintf(`({p} IS NULL OR NOT EXISTS (
+        SELECT 1 FROM %s L2
+        WHERE L2.DocEntry = H.DocEntry AND L2.WhsCode = {p}
+      ))`, t.Lines)
+		}},
+		{Name: "itemCode", Value: optionalStringArg(query.ItemCode), Clause: func(t sapDocTable) string {
+			if !t.HasItemLines {
+				return "{p} IS NULL"
+			}
+			return fmt.Sprintf(`({p} IS NULL OR EXISTS (
+        SELECT 1 FROM %s L3
+        WHERE L3.DocEntry = H.DocEntry AND L3.ItemCode = {p}
+      ))`, t.Lines)
+		}},
+		{Name: "slpCode", Value: optionalIntArg(query.SlpCode), Clause: func(t sapDocTable) string {
+			if !t.HasSlpCode {
+				return "{p} IS NULL"
+			}
+			return "({p} IS NULL OR H.SlpCode = {p})"
+		}},
+		{Name: "text", Value: text, Clause: func(t sapDocTable) string {
+			return fmt.Sprintf(`({p} IS NULL OR H.%s LIKE {p} ESCAPE '\' OR H.Comments LIKE {p} ESCAPE '\')`, t.refColumn())
+		}},
+		{Name: "docTotalMin", Value: optionalFloatArg(query.DocTotalMin), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.DocTotal >= {p})"
+		}},
+		{Name: "docTotalMax", Value: optionalFloatArg(query.DocTotalMax), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.DocTotal <= {p})"
+		}},
+		{Name: "currency", Value: optionalStringArg(query.Currency), Clause: func(t sapDocTable) string {
+			return fmt.Sprintf("({p} IS NULL OR H.%s = {p})", t.currencyColumn())
+		}},
+		{Name: "canceled", Value: canceled, Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.CANCELED = {p})"
+		}},
+		{Name: "series", Value: optionalIntArg(query.Series), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.Series = {p})"
+		}},
+		{Name: "updateDateFrom", Value: optionalTimeArg(query.UpdateDateFrom), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.UpdateDate >= {p})"
+		}},
+		{Name: "updateDateTo", Value: optionalTimeArg(query.UpdateDateTo), Clause: func(sapDocTable) string {
+			return "({p} IS NULL OR H.UpdateDate <= {p})"
+		}},
+	}
+}
+
+// sapDocumentsArgsMSSQL binds every filter once by name, the clauses of all
+// tables in a UNION share them.
+func sapDocumentsArgsMSSQL(query SapDocumentsQuery) []any {
+	filters := sapDocumentFilters(query)
+	args := make([]any, 0, len(filters))
+	for _, filter := range filters {
+		args = append(args, sql.Named(filter.Name, filter.Value))
+	}
+	return args
+}
+
+// sapDocumentsWhereMSSQL leaves the arguments to sapDocumentsArgsMSSQL.
+func sapDocumentsWhereMSSQL(query SapDocumentsQuery, tableDef sapDocTable) string {
+	whereClause, _ := sapDocumentsWhere("mssql", query, tableDef)
+	return whereClause
+}
+
+// sapDocumentsWhereHANA repeats the positional argument for every {p} of a clause.
+func sapDocumentsWhereHANA(query SapDocumentsQuery, tableDef sapDocTable) (string, []any) {
+	return sapDocumentsWhere("hana", query, tableDef)
+}
+
+func sapDocumentsWhere(dialect string, query SapDocumentsQuery, tableDef sapDocTable) (string, []any) {
+	conditions := make([]string, 0)
+	args := make([]any, 0)
+	for _, filter := range sapDocumentFilters(query) {
+		clause := filter.Clause(tableDef)
+		if clause == "" {
+			continue
+		}
+		// both dialects are known here, Param cannot fail
+		condition, filterArgs, _ := db.Param(dialect, clause, filter.Name, filter.Value)
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+	return strings.Join(conditions, "\n  AND "), args
+}
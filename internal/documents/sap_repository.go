@@ -7,12 +7,55 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/db"
 )
 
+// sapDocTable describes one SAP document type. Not every type supports every
+// filter: incoming payments have no DocStatus or sales employee, their RCT2
+// lines carry no items or warehouse and point at the header via DocNum rather
+// than DocEntry.
 type sapDocTable struct {
-	DocType string
-	Header  string
-	Lines   string
+	DocType        string
+	ObjType        int
+	Header         string
+	Lines          string
+	LineKey        string
+	LineNum        string
+	CurrencyColumn string
+	RefColumn      string
+	HasItemLines   bool
+	HasDocStatus   bool
+	HasSlpCode     bool
+}
+
+func (t sapDocTable) lineKey() string {
+	if t.LineKey == "" {
+		return "DocEntry"
+	}
+	return t.LineKey
+}
+
+func (t sapDocTable) lineNum() string {
+	if t.LineNum == "" {
+		return "LineNum"
+	}
+	return t.LineNum
+}
+
+func (t sapDocTable) currencyColumn() string {
+	if t.CurrencyColumn == "" {
+		return "DocCur"
+	}
+	return t.CurrencyColumn
+}
+
+func (t sapDocTable) refColumn() string {
+	if t.RefColumn == "" {
+		return "NumAtCard"
+	}
+	return t.RefColumn
 }
 
 type sapDocumentKey struct {
@@ -26,11 +69,17 @@ type sqlQuery struct {
 }
 
 var sapDocTables = []sapDocTable{
-	{DocType: "Orders", Header: "ORDR", Lines: "RDR1"},
-	{DocType: "Invoices", Header: "OINV", Lines: "INV1"},
-	{DocType: "Returns", Header: "ORDN", Lines: "RDN1"},
-	{DocType: "Quotations", Header: "OQUT", Lines: "QUT1"},
-	{DocType: "DeliveryNotes", Header: "ODLN", Lines: "DLN1"},
+	{DocType: "Orders", ObjType: 17, Header: "ORDR", Lines: "RDR1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "Invoices", ObjType: 13, Header: "OINV", Lines: "INV1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "Returns", ObjType: 16, Header: "ORDN", Lines: "RDN1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "Quotations", ObjType: 23, Header: "OQUT", Lines: "QUT1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "DeliveryNotes", ObjType: 15, Header: "ODLN", Lines: "DLN1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "CreditNotes", ObjType: 14, Header: "ORIN", Lines: "RIN1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "DownPayments", ObjType: 203, Header: "ODPI", Lines: "DPI1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "PurchaseOrders", ObjType: 22, Header: "OPOR", Lines: "POR1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "GoodsReceipts", ObjType: 20, Header: "OPDN", Lines: "PDN1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "PurchaseInvoices", ObjType: 18, Header: "OPCH", Lines: "PCH1", HasItemLines: true, HasDocStatus: true, HasSlpCode: true},
+	{DocType: "IncomingPayments", ObjType: 24, Header: "ORCT", Lines: "RCT2", LineKey: "DocNum", LineNum: "InvoiceId", CurrencyColumn: "DocCurr", RefColumn: "CounterRef"},
 }
 
 var sapDocTableByType = func() map[string]sapDocTable {
@@ -41,39 +90,89 @@ var sapDocTableByType = func() map[string]sapDocTable {
 	return lookup
 }()
 
+// sapDocTableByObjType resolves the BaseType/TargetType codes found on document lines.
+var sapDocTableByObjType = func() map[int]sapDocTable {
+	lookup := make(map[int]sapDocTable, len(sapDocTables))
+	for _, entry := range sapDocTables {
+		lookup[entry.ObjType] = entry
+	}
+	return lookup
+}()
+
 func (r *DocumentRrepository) GetSapDocuments(ctx context.Context, query SapDocumentsQuery) (SapDocumentsResponse, error) {
-	countQuery, keysQuery, err := buildSapDocumentsQueries(r.Db.Dialect, query)
+	columnsByType, err := r.resolveSapDocumentColumns(ctx, query)
 	if err != nil {
 		return SapDocumentsResponse{}, err
 	}
 
-	var total int
-	if err := r.Db.QueryRowContext(ctx, countQuery.Query, countQuery.Args...).Scan(&total); err != nil {
+	countQuery, keysQuery, err := buildSapDocumentsQueries(r.Db.Dialect, query)
+	if err != nil {
 		return SapDocumentsResponse{}, err
 	}
+	keyset := query.Paging != sapPagingOffset
+	if keyset {
+		keysQuery, err = buildSapDocumentsKeysetQuery(r.Db.Dialect, query)
+		if err != nil {
+			return SapDocumentsResponse{}, err
+		}
+	}
+
+	var (
+		totals map[string]int
+		total  *int
+	)
+	if !keyset {
+		totals, err = r.countSapDocuments(ctx, countQuery, query.DocTypes)
+		if err != nil {
+			return SapDocumentsResponse{}, err
+		}
+		sum := 0
+		for _, count := range totals {
+			sum += count
+		}
+		total = &sum
+	}
 
 	keysRows, err := r.Db.QueryContext(ctx, keysQuery.Query, keysQuery.Args...)
 	if err != nil {
-		return SapDocumentsResponse{}, err
+		return SapDocumentsResponse{}, apperr.Upstream("failed to fetch documents", err)
 	}
 	defer keysRows.Close()
 
 	keys := make([]sapDocumentKey, 0, query.PageSize)
 	docEntriesByType := make(map[string][]int64)
+	var last *SapDocumentsCursor
 	for keysRows.Next() {
 		var (
-			docType  string
-			docEntry int64
-			docDate  time.Time
+			docType    string
+			docEntry   int64
+			docDate    time.Time
+			updateDate sql.NullTime
+			updateTS   int
 		)
-		if err := keysRows.Scan(&docType, &docEntry, &docDate); err != nil {
-			return SapDocumentsResponse{}, err
+		dest := []any{&docType, &docEntry, &docDate}
+		if keyset {
+			dest = append(dest, &updateDate, &updateTS)
+		}
+		if err := keysRows.Scan(dest...); err != nil {
+			return SapDocumentsResponse{}, apperr.Upstream("failed to fetch documents", err)
 		}
 		keys = append(keys, sapDocumentKey{DocType: docType, DocEntry: docEntry})
 		docEntriesByType[docType] = append(docEntriesByType[docType], docEntry)
+
+		if keyset {
+			last = &SapDocumentsCursor{Mode: query.Paging, DocEntry: docEntry}
+			if query.Paging == sapPagingChanges {
+				last.Date = updateDate.Time.Format("2006-01-02")
+				last.Time = updateTS
+			} else {
+				last.Dir = query.SortDir
+				last.Date = docDate.Format("2006-01-02")
+			}
+		}
 	}
 	if err := keysRows.Err(); err != nil {
-		return SapDocumentsResponse{}, err
+		return SapDocumentsResponse{}, apperr.Upstream("failed to fetch documents", err)
 	}
 
 	rowsByKey := make(map[string]map[string]any, len(keys))
@@ -88,23 +187,15 @@ func (r *DocumentRrepository) GetSapDocuments(ctx context.Context, query SapDocu
 			continue
 		}
 
-		selectQuery, selectArgs, err := buildSapDocumentsSelect(r.Db.Dialect, tableDef.Header, entries)
+		rowMap, err := r.loadSapDocumentHeaders(ctx, tableDef, columnsByType[docType], entries)
 		if err != nil {
 			return SapDocumentsResponse{}, err
 		}
 
-		rows, err := r.Db.QueryContext(ctx, selectQuery, selectArgs...)
-		if err != nil {
-			return SapDocumentsResponse{}, err
-		}
-
-		rowMap, scanErr := scanRowsByDocEntry(rows, docType)
-		closeErr := rows.Close()
-		if scanErr != nil {
-			return SapDocumentsResponse{}, scanErr
-		}
-		if closeErr != nil {
-			return SapDocumentsResponse{}, closeErr
+		if query.IncludeLines {
+			if err := r.attachSapDocumentLines(ctx, tableDef, entries, rowMap); err != nil {
+				return SapDocumentsResponse{}, err
+			}
 		}
 
 		for key, value := range rowMap {
@@ -120,25 +211,162 @@ func (r *DocumentRrepository) GetSapDocuments(ctx context.Context, query SapDocu
 		}
 	}
 
-	return SapDocumentsResponse{
+	response := SapDocumentsResponse{
 		Page:     query.Page,
 		PageSize: query.PageSize,
 		Total:    total,
+		Totals:   totals,
 		Items:    items,
-	}, nil
+	}
+
+	// a full page may have more behind it; the checkpoint of a change feed is
+	// kept even when the page is empty so the sync job can resume from it
+	if last != nil && len(keys) == query.PageSize {
+		response.NextCursor = encodeSapDocumentsCursor(*last)
+	}
+	if query.Paging == sapPagingChanges {
+		switch {
+		case last != nil:
+			response.Checkpoint = encodeSapDocumentsCursor(*last)
+		case query.Cursor != nil:
+			response.Checkpoint = encodeSapDocumentsCursor(*query.Cursor)
+		}
+	}
+
+	return response, nil
 }
 
-func buildSapDocumentsQueries(dialect string, query SapDocumentsQuery) (sqlQuery, sqlQuery, error) {
-	tableDef, ok := sapDocTableByType[query.DocType]
+// countSapDocuments reads the (docType, count) rows of a count query; every
+// requested type is present in the result, even when nothing matched.
+func (r *DocumentRrepository) countSapDocuments(ctx context.Context, countQuery sqlQuery, docTypes []string) (map[string]int, error) {
+	totals := make(map[string]int, len(docTypes))
+	for _, docType := range docTypes {
+		totals[docType] = 0
+	}
+
+	rows, err := r.Db.QueryContext(ctx, countQuery.Query, countQuery.Args...)
+	if err != nil {
+		return nil, apperr.Upstream("failed to count documents", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			docType string
+			count   int
+		)
+		if err := rows.Scan(&docType, &count); err != nil {
+			return nil, apperr.Upstream("failed to count documents", err)
+		}
+		totals[docType] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("failed to count documents", err)
+	}
+	return totals, nil
+}
+
+// GetSapDocument returns one document header with its lines.
+func (r *DocumentRrepository) GetSapDocument(ctx context.Context, docType string, docEntry int64) (map[string]any, error) {
+	tableDef, ok := sapDocTableByType[docType]
 	if !ok {
-		return sqlQuery{}, sqlQuery{}, fmt.Errorf("unsupported docType: %s", query.DocType)
+		return nil, apperr.Validation("invalid docType", docTypeAllowedDetails())
+	}
+
+	rowMap, err := r.loadSapDocumentHeaders(ctx, tableDef, nil, []int64{docEntry})
+	if err != nil {
+		return nil, err
+	}
+	if len(rowMap) == 0 {
+		return nil, apperr.NotFound("document not found")
+	}
+
+	if err := r.attachSapDocumentLines(ctx, tableDef, []int64{docEntry}, rowMap); err != nil {
+		return nil, err
+	}
+
+	return rowMap[sapDocumentKeyString(docType, docEntry)], nil
+}
+
+// loadSapDocumentHeaders selects the given columns of the headers, all of them when columns is nil.
+func (r *DocumentRrepository) loadSapDocumentHeaders(ctx context.Context, tableDef sapDocTable, columns []string, entries []int64) (map[string]map[string]any, error) {
+	selectQuery, selectArgs, err := buildSapDocumentsSelect(r.Db.Dialect, tableDef.Header, columns, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.QueryContext(ctx, selectQuery, selectArgs...)
+	if err != nil {
+		return nil, apperr.Upstream("failed to fetch documents", err)
+	}
+
+	rowMap, scanErr := scanRowsByDocEntry(rows, tableDef.DocType)
+	closeErr := rows.Close()
+	if scanErr != nil {
+		return nil, apperr.Upstream("failed to fetch documents", scanErr)
+	}
+	if closeErr != nil {
+		return nil, apperr.Upstream("failed to fetch documents", closeErr)
+	}
+	return rowMap, nil
+}
+
+// attachSapDocumentLines loads the lines of all entries in one query and sets
+// them under "lines" on the matching header rows.
+func (r *DocumentRrepository) attachSapDocumentLines(ctx context.Context, tableDef sapDocTable, entries []int64, headers map[string]map[string]any) error {
+	selectQuery, selectArgs, err := buildSapDocumentLinesSelect(r.Db.Dialect, tableDef, entries)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.Db.QueryContext(ctx, selectQuery, selectArgs...)
+	if err != nil {
+		return apperr.Upstream("failed to fetch document lines", err)
+	}
+
+	linesByKey, scanErr := scanLinesByDocEntry(rows, tableDef.DocType, tableDef.lineKey())
+	closeErr := rows.Close()
+	if scanErr != nil {
+		return apperr.Upstream("failed to fetch document lines", scanErr)
+	}
+	if closeErr != nil {
+		return apperr.Upstream("failed to fetch document lines", closeErr)
+	}
+
+	for key, header := range headers {
+		lines := linesByKey[key]
+		if lines == nil {
+			lines = []map[string]any{}
+		}
+		header["lines"] = lines
+	}
+	return nil
+}
+
+func buildSapDocumentsQueries(dialect string, query SapDocumentsQuery) (sqlQuery, sqlQuery, error) {
+	tables := make([]sapDocTable, 0, len(query.DocTypes))
+	for _, docType := range query.DocTypes {
+		tableDef, ok := sapDocTableByType[docType]
+		if !ok {
+			return sqlQuery{}, sqlQuery{}, fmt.Errorf("unsupported docType: %s", docType)
+		}
+		tables = append(tables, tableDef)
+	}
+	if len(tables) == 0 {
+		return sqlQuery{}, sqlQuery{}, fmt.Errorf("docType is required")
 	}
 
 	switch strings.ToLower(dialect) {
 	case "", "mssql":
-		return buildSapDocumentsQueriesMSSQL(query, tableDef)
+		if len(tables) == 1 {
+			return buildSapDocumentsQueriesMSSQL(query, tables[0])
+		}
+		return buildSapDocumentsUnionQueriesMSSQL(query, tables)
 	case "hana":
-		return buildSapDocumentsQueriesHANA(query, tableDef)
+		if len(tables) == 1 {
+			return buildSapDocumentsQueriesHANA(query, tables[0])
+		}
+		return buildSapDocumentsUnionQueriesHANA(query, tables)
 	default:
 		return sqlQuery{}, sqlQuery{}, fmt.Errorf("unsupported db dialect: %s", dialect)
 	}
@@ -150,29 +378,11 @@ func buildSapDocumentsQueriesMSSQL(query SapDocumentsQuery, tableDef sapDocTable
 		return sqlQuery{}, sqlQuery{}, err
 	}
 
-	baseArgs := []any{
-		sql.Named("dateFrom", query.DateFrom),
-		sql.Named("dateTo", query.DateTo),
-		sql.Named("cardCode", optionalStringArg(query.CardCode)),
-		sql.Named("docStatus", optionalStringArg(query.DocStatus)),
-		sql.Named("warehouseCode", optionalStringArg(query.WarehouseCode)),
-		sql.Named("warehouseCodeNotEqual", optionalStringArg(query.WarehouseCodeNotEqual)),
-	}
-
-	whereClause := fmt.Sprintf(`H.DocDate >= @dateFrom AND H.DocDate <= @dateTo
-  AND (@cardCode IS NULL OR H.CardCode = @cardCode)
-  AND (@docStatus IS NULL OR H.DocStatus = @docStatus)
-  AND (@warehouseCode IS NULL OR EXISTS (
-        SELECT 1 FROM %s L
-        WHERE L.DocEntry = H.DocEntry AND L.WhsCode = @warehouseCode
-      ))
-  AND (@warehouseCodeNotEqual IS NULL OR NOT EXISTS (
-        SELECT 1 FROM %s L2
-        WHERE L2.DocEntry = H.DocEntry AND L2.WhsCode = @warehouseCodeNotEqual
-      ))`, tableDef.Lines, tableDef.Lines)
+	baseArgs := sapDocumentsArgsMSSQL(query)
+	whereClause := sapDocumentsWhereMSSQL(query, tableDef)
 
 	countQuery := sqlQuery{
-		Query: fmt.Sprintf("SELECT COUNT(1) FROM %s H WHERE %s", tableDef.Header, whereClause),
+		Query: fmt.Sprintf("SELECT '%s' AS docType, COUNT(1) FROM %s H WHERE %s", tableDef.DocType, tableDef.Header, whereClause),
 		Args:  baseArgs,
 	}
 
@@ -197,37 +407,10 @@ func buildSapDocumentsQueriesHANA(query SapDocumentsQuery, tableDef sapDocTable)
 		return sqlQuery{}, sqlQuery{}, err
 	}
 
-	cardCode := optionalStringArg(query.CardCode)
-	docStatus := optionalStringArg(query.DocStatus)
-	warehouseCode := optionalStringArg(query.WarehouseCode)
-	warehouseCodeNotEqual := optionalStringArg(query.WarehouseCodeNotEqual)
-	baseArgs := []any{
-		query.DateFrom,
-		query.DateTo,
-		cardCode,
-		cardCode,
-		docStatus,
-		docStatus,
-		warehouseCode,
-		warehouseCode,
-		warehouseCodeNotEqual,
-		warehouseCodeNotEqual,
-	}
-
-	whereClause := fmt.Sprintf(`H.DocDate >= ? AND H.DocDate <= ?
-  AND (? IS NULL OR H.CardCode = ?)
-  AND (? IS NULL OR H.DocStatus = ?)
-  AND (? IS NULL OR EXISTS (
-        SELECT 1 FROM %s L
-        WHERE L.DocEntry = H.DocEntry AND L.WhsCode = ?
-      ))
-  AND (? IS NULL OR NOT EXISTS (
-        SELECT 1 FROM %s L2
-        WHERE L2.DocEntry = H.DocEntry AND L2.WhsCode = ?
-      ))`, tableDef.Lines, tableDef.Lines)
+	whereClause, baseArgs := sapDocumentsWhereHANA(query, tableDef)
 
 	countQuery := sqlQuery{
-		Query: fmt.Sprintf("SELECT COUNT(1) FROM %s H WHERE %s", tableDef.Header, whereClause),
+		Query: fmt.Sprintf("SELECT '%s' AS docType, COUNT(1) FROM %s H WHERE %s", tableDef.DocType, tableDef.Header, whereClause),
 		Args:  baseArgs,
 	}
 
@@ -247,52 +430,83 @@ func buildSapDocumentsQueriesHANA(query SapDocumentsQuery, tableDef sapDocTable)
 	return countQuery, keysQuery, nil
 }
 
-func buildSapDocumentsUnionMSSQL() string {
-	parts := make([]string, 0, len(sapDocTables))
-	for _, entry := range sapDocTables {
+// buildSapDocumentsUnionQueriesMSSQL pages across several document types at once,
+// the count query returns one row per docType.
+func buildSapDocumentsUnionQueriesMSSQL(query SapDocumentsQuery, tables []sapDocTable) (sqlQuery, sqlQuery, error) {
+	orderClause, err := buildSapDocumentsOrderBy(query.SortBy, query.SortDir)
+	if err != nil {
+		return sqlQuery{}, sqlQuery{}, err
+	}
+
+	union := buildSapDocumentsUnionMSSQL(query, tables)
+	baseArgs := sapDocumentsArgsMSSQL(query)
+
+	countQuery := sqlQuery{
+		Query: fmt.Sprintf("SELECT U.docType, COUNT(1) FROM (%s) U GROUP BY U.docType", union),
+		Args:  baseArgs,
+	}
+
+	offset := (query.Page - 1) * query.PageSize
+	keysQuery := sqlQuery{
+		Query: fmt.Sprintf(
+			"SELECT U.docType, U.DocEntry, U.DocDate FROM (%s) U ORDER BY %s, docType ASC OFFSET @offset ROWS FETCH NEXT @pageSize ROWS ONLY",
+			union,
+			orderClause,
+		),
+		Args: append(append([]any{}, baseArgs...), sql.Named("offset", offset), sql.Named("pageSize", query.PageSize)),
+	}
+
+	return countQuery, keysQuery, nil
+}
+
+func buildSapDocumentsUnionQueriesHANA(query SapDocumentsQuery, tables []sapDocTable) (sqlQuery, sqlQuery, error) {
+	orderClause, err := buildSapDocumentsOrderBy(query.SortBy, query.SortDir)
+	if err != nil {
+		return sqlQuery{}, sqlQuery{}, err
+	}
+
+	union, baseArgs := buildSapDocumentsUnionHANA(query, tables)
+
+	countQuery := sqlQuery{
+		Query: fmt.Sprintf("SELECT U.docType, COUNT(1) FROM (%s) U GROUP BY U.docType", union),
+		Args:  baseArgs,
+	}
+
+	offset := (query.Page - 1) * query.PageSize
+	keysQuery := sqlQuery{
+		Query: fmt.Sprintf(
+			"SELECT U.docType, U.DocEntry, U.DocDate FROM (%s) U ORDER BY %s, docType ASC LIMIT ? OFFSET ?",
+			union,
+			orderClause,
+		),
+		Args: append(append([]any{}, baseArgs...), query.PageSize, offset),
+	}
+
+	return countQuery, keysQuery, nil
+}
+
+func buildSapDocumentsUnionMSSQL(query SapDocumentsQuery, tables []sapDocTable) string {
+	parts := make([]string, 0, len(tables))
+	for _, entry := range tables {
 		part := fmt.Sprintf(`SELECT '%s' AS docType, H.DocEntry, H.DocDate
 FROM %s H
-WHERE H.DocDate >= @dateFrom AND H.DocDate <= @dateTo
-  AND (@cardCode IS NULL OR H.CardCode = @cardCode)
-  AND (@docStatus IS NULL OR H.DocStatus = @docStatus)
-  AND (@warehouseCode IS NULL OR EXISTS (
-        SELECT 1 FROM %s L
-        WHERE L.DocEntry = H.DocEntry AND L.WhsCode = @warehouseCode
-      ))`, entry.DocType, entry.Header, entry.Lines)
+WHERE %s`, entry.DocType, entry.Header, sapDocumentsWhereMSSQL(query, entry))
 		parts = append(parts, part)
 	}
 	return strings.Join(parts, "\nUNION ALL\n")
 }
 
-func buildSapDocumentsUnionHANA(query SapDocumentsQuery) (string, []any) {
-	parts := make([]string, 0, len(sapDocTables))
-	args := make([]any, 0, len(sapDocTables)*8)
+func buildSapDocumentsUnionHANA(query SapDocumentsQuery, tables []sapDocTable) (string, []any) {
+	parts := make([]string, 0, len(tables))
+	args := make([]any, 0, len(tables)*10)
 
-	cardCode := optionalStringArg(query.CardCode)
-	docStatus := optionalStringArg(query.DocStatus)
-	warehouseCode := optionalStringArg(query.WarehouseCode)
-
-	for _, entry := range sapDocTables {
+	for _, entry := range tables {
+		whereClause, whereArgs := sapDocumentsWhereHANA(query, entry)
 		part := fmt.Sprintf(`SELECT '%s' AS docType, H.DocEntry, H.DocDate
 FROM %s H
-WHERE H.DocDate >= ? AND H.DocDate <= ?
-  AND (? IS NULL OR H.CardCode = ?)
-  AND (? IS NULL OR H.DocStatus = ?)
-  AND (? IS NULL OR EXISTS (
-        SELECT 1 FROM %s L
-        WHERE L.DocEntry = H.DocEntry AND L.WhsCode = ?
-      ))`, entry.DocType, entry.Header, entry.Lines)
+WHERE %s`, entry.DocType, entry.Header, whereClause)
 		parts = append(parts, part)
-		args = append(args,
-			query.DateFrom,
-			query.DateTo,
-			cardCode,
-			cardCode,
-			docStatus,
-			docStatus,
-			warehouseCode,
-			warehouseCode,
-		)
+		args = append(args, whereArgs...)
 	}
 
 	return strings.Join(parts, "\nUNION ALL\n"), args
@@ -324,11 +538,24 @@ func buildSapDocumentsOrderBy(sortBy, sortDir string) (string, error) {
 	return fmt.Sprintf("%s %s, %s %s", column, direction, tieBreaker, direction), nil
 }
 
-func buildSapDocumentsSelect(dialect, table string, entries []int64) (string, []any, error) {
+func buildSapDocumentsSelect(dialect, table string, columns []string, entries []int64) (string, []any, error) {
+	return buildSapDocumentsSelectBy(dialect, table, "DocEntry", columns, entries)
+}
+
+func buildSapDocumentsSelectBy(dialect, table, keyColumn string, columns []string, entries []int64) (string, []any, error) {
 	if len(entries) == 0 {
 		return "", nil, fmt.Errorf("empty DocEntry list for %s", table)
 	}
 
+	projection := "*"
+	if len(columns) > 0 {
+		quoted := make([]string, len(columns))
+		for i, column := range columns {
+			quoted[i] = db.QuoteIdentifier(dialect, column)
+		}
+		projection = strings.Join(quoted, ", ")
+	}
+
 	switch strings.ToLower(dialect) {
 	case "", "mssql":
 		placeholders := make([]string, len(entries))
@@ -338,7 +565,7 @@ func buildSapDocumentsSelect(dialect, table string, entries []int64) (string, []
 			placeholders[i] = "@" + name
 			args = append(args, sql.Named(name, entry))
 		}
-		return fmt.Sprintf("SELECT * FROM %s WHERE DocEntry IN (%s)", table, strings.Join(placeholders, ", ")), args, nil
+		return fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)", projection, table, keyColumn, strings.Join(placeholders, ", ")), args, nil
 	case "hana":
 		placeholders := make([]string, len(entries))
 		args := make([]any, 0, len(entries))
@@ -346,12 +573,20 @@ func buildSapDocumentsSelect(dialect, table string, entries []int64) (string, []
 			placeholders[i] = "?"
 			args = append(args, entry)
 		}
-		return fmt.Sprintf("SELECT * FROM %s WHERE DocEntry IN (%s)", table, strings.Join(placeholders, ", ")), args, nil
+		return fmt.Sprintf("SELECT %s FROM %s WHERE %s IN (%s)", projection, table, keyColumn, strings.Join(placeholders, ", ")), args, nil
 	default:
 		return "", nil, fmt.Errorf("unsupported db dialect: %s", dialect)
 	}
 }
 
+func buildSapDocumentLinesSelect(dialect string, tableDef sapDocTable, entries []int64) (string, []any, error) {
+	selectQuery, args, err := buildSapDocumentsSelectBy(dialect, tableDef.Lines, tableDef.lineKey(), nil, entries)
+	if err != nil {
+		return "", nil, err
+	}
+	return fmt.Sprintf("%s ORDER BY %s, %s", selectQuery, tableDef.lineKey(), tableDef.lineNum()), args, nil
+}
+
 func scanRowsByDocEntry(rows *sql.Rows, docType string) (map[string]map[string]any, error) {
 	columns, err := rows.Columns()
 	if err != nil {
@@ -400,6 +635,53 @@ func scanRowsByDocEntry(rows *sql.Rows, docType string) (map[string]map[string]a
 	return result, nil
 }
 
+func scanLinesByDocEntry(rows *sql.Rows, docType, keyColumn string) (map[string][]map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	docEntryIndex := -1
+	for i, column := range columns {
+		if strings.EqualFold(column, keyColumn) {
+			docEntryIndex = i
+			break
+		}
+	}
+	if docEntryIndex == -1 {
+		return nil, fmt.Errorf("%s column not found for %s lines", keyColumn, docType)
+	}
+
+	result := make(map[string][]map[string]any)
+	for rows.Next() {
+		raw := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		rowMap := make(map[string]any, len(columns))
+		for i, column := range columns {
+			rowMap[column] = normalizeSQLValue(raw[i])
+		}
+
+		key, ok := docEntryKeyFromValue(docType, raw[docEntryIndex])
+		if !ok {
+			return nil, fmt.Errorf("invalid %s value for %s lines", keyColumn, docType)
+		}
+		result[key] = append(result[key], rowMap)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
 func normalizeSQLValue(value any) any {
 	switch typed := value.(type) {
 	case nil:
@@ -465,3 +747,32 @@ func optionalStringArg(value *string) any {
 	}
 	return *value
 }
+
+func optionalIntArg(value *int) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func optionalFloatArg(value *float64) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+// optionalDateArg treats the zero time as not set, the date range is optional in changes mode.
+func optionalDateArg(value time.Time) any {
+	if value.IsZero() {
+		return nil
+	}
+	return value
+}
+
+func optionalTimeArg(value *time.Time) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
@@ -0,0 +1,224 @@
+package documents
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"sql-service/pkg/apperr"
+)
+
+// sapSummaryGroup describes one groupBy option. Line level groups sum the
+// document lines (net LineTotal, line VatSum) instead of the headers, so their
+// amount is reported as lineTotal rather than docTotal.
+type sapSummaryGroup struct {
+	LineLevel bool
+	Ordered   bool
+	Joins     string
+	KeyMSSQL  string
+	KeyHANA   string
+	Label     string
+}
+
+var sapSummaryGroups = map[string]sapSummaryGroup{
+	"day": {
+		Ordered:  true,
+		KeyMSSQL: "CONVERT(char(10), H.DocDate, 23)",
+		KeyHANA:  "TO_VARCHAR(H.DocDate, 'YYYY-MM-DD')",
+	},
+	"week": {
+		Ordered:  true,
+		KeyMSSQL: "CONCAT(YEAR(DATEADD(day, 26 - DATEPART(iso_week, H.DocDate), H.DocDate)), '-W', RIGHT('0' + CAST(DATEPART(iso_week, H.DocDate) AS varchar(2)), 2))",
+		KeyHANA:  "ISOWEEK(H.DocDate)",
+	},
+	"month": {
+		Ordered:  true,
+		KeyMSSQL: "CONVERT(char(7), H.DocDate, 23)",
+		KeyHANA:  "TO_VARCHAR(H.DocDate, 'YYYY-MM')",
+	},
+	"customer": {
+		KeyMSSQL: "H.CardCode",
+		KeyHANA:  "H.CardCode",
+		Label:    "H.CardName",
+	},
+	"salesEmployee": {
+		Joins:    "LEFT JOIN OSLP S ON S.SlpCode = H.SlpCode",
+		KeyMSSQL: "CAST(H.SlpCode AS nvarchar(11))",
+		KeyHANA:  "TO_NVARCHAR(H.SlpCode)",
+		Label:    "S.SlpName",
+	},
+	"itemGroup": {
+		LineLevel: true,
+		Joins:     "LEFT JOIN OITM I ON I.ItemCode = L.ItemCode LEFT JOIN OITB G ON G.ItmsGrpCod = I.ItmsGrpCod",
+		KeyMSSQL:  "CAST(I.ItmsGrpCod AS nvarchar(11))",
+		KeyHANA:   "TO_NVARCHAR(I.ItmsGrpCod)",
+		Label:     "G.ItmsGrpNam",
+	},
+	"warehouse": {
+		LineLevel: true,
+		Joins:     "LEFT JOIN OWHS W ON W.WhsCode = L.WhsCode",
+		KeyMSSQL:  "L.WhsCode",
+		KeyHANA:   "L.WhsCode",
+		Label:     "W.WhsName",
+	},
+}
+
+// credit notes and returns reverse the documents they are based on
+var sapSummaryReversing = map[string]bool{
+	"CreditNotes": true,
+	"Returns":     true,
+}
+
+func (r *DocumentRrepository) GetSapDocumentsSummary(ctx context.Context, query SapDocumentsQuery, groupBy string) ([]SapSummaryRow, error) {
+	summaryQuery, err := buildSapDocumentsSummaryQuery(r.Db.Dialect, query, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.QueryContext(ctx, summaryQuery.Query, summaryQuery.Args...)
+	if err != nil {
+		return nil, apperr.Upstream("failed to summarize documents", err)
+	}
+	defer rows.Close()
+
+	summary := make([]SapSummaryRow, 0)
+	for rows.Next() {
+		var (
+			row      SapSummaryRow
+			key      sql.NullString
+			label    sql.NullString
+			amount   sql.NullFloat64
+			vatSum   sql.NullFloat64
+			quantity sql.NullFloat64
+		)
+		if err := rows.Scan(&key, &label, &amount, &vatSum, &quantity, &row.Documents); err != nil {
+			return nil, apperr.Upstream("failed to summarize documents", err)
+		}
+		row.Key = key.String
+		if label.Valid {
+			row.Label = &label.String
+		}
+		total := amount.Float64
+		if sapSummaryGroups[groupBy].LineLevel {
+			row.LineTotal = &total
+		} else {
+			row.DocTotal = &total
+		}
+		row.VatSum = vatSum.Float64
+		row.Quantity = quantity.Float64
+		summary = append(summary, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("failed to summarize documents", err)
+	}
+
+	return summary, nil
+}
+
+// CountSapDocuments counts the documents matching the query over all its docTypes.
+func (r *DocumentRrepository) CountSapDocuments(ctx context.Context, query SapDocumentsQuery) (int, error) {
+	countQuery, _, err := buildSapDocumentsQueries(r.Db.Dialect, query)
+	if err != nil {
+		return 0, err
+	}
+
+	totals, err := r.countSapDocuments(ctx, countQuery, query.DocTypes)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, count := range totals {
+		total += count
+	}
+	return total, nil
+}
+
+// buildSapDocumentsSummaryQuery unions one projection per docType, reusing the
+// documents search filters, and aggregates it by the group key.
+func buildSapDocumentsSummaryQuery(dialect string, query SapDocumentsQuery, groupBy string) (sqlQuery, error) {
+	group, ok := sapSummaryGroups[groupBy]
+	if !ok {
+		return sqlQuery{}, fmt.Errorf("unsupported groupBy: %s", groupBy)
+	}
+
+	hana := false
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+	case "hana":
+		hana = true
+	default:
+		return sqlQuery{}, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+
+	key := group.KeyMSSQL
+	documentID := "CONCAT(U.docType, ':', U.DocEntry)"
+	if hana {
+		key = group.KeyHANA
+		documentID = "U.docType || ':' || TO_NVARCHAR(U.DocEntry)"
+	}
+	label := "NULL"
+	if group.Label != "" {
+		label = group.Label
+	}
+
+	parts := make([]string, 0, len(query.DocTypes))
+	var args []any
+	if !hana {
+		args = sapDocumentsArgsMSSQL(query)
+	}
+
+	for _, docType := range query.DocTypes {
+		tableDef, ok := sapDocTableByType[docType]
+		if !ok {
+			return sqlQuery{}, fmt.Errorf("unsupported docType: %s", docType)
+		}
+
+		var whereClause string
+		if hana {
+			var whereArgs []any
+			whereClause, whereArgs = sapDocumentsWhereHANA(query, tableDef)
+			args = append(args, whereArgs...)
+		} else {
+			whereClause = sapDocumentsWhereMSSQL(query, tableDef)
+		}
+
+		sign := 1
+		if sapSummaryReversing[docType] {
+			sign = -1
+		}
+
+		if group.LineLevel {
+			parts = append(parts, fmt.Sprintf(`SELECT %[1]s AS groupKey, %[2]s AS groupLabel,
+    L.LineTotal * %[3]d AS amount, L.VatSum * %[3]d AS vatSum, L.Quantity * %[3]d AS quantity,
+    '%[4]s' AS docType, H.DocEntry
+FROM %[5]s H
+JOIN %[6]s L ON L.DocEntry = H.DocEntry
+%[7]s
+WHERE %[8]s`, key, label, sign, tableDef.DocType, tableDef.Header, tableDef.Lines, group.Joins, whereClause))
+			continue
+		}
+
+		parts = append(parts, fmt.Sprintf(`SELECT %[1]s AS groupKey, %[2]s AS groupLabel,
+    H.DocTotal * %[3]d AS amount, H.VatSum * %[3]d AS vatSum,
+    (SELECT SUM(LQ.Quantity) FROM %[6]s LQ WHERE LQ.DocEntry = H.DocEntry) * %[3]d AS quantity,
+    '%[4]s' AS docType, H.DocEntry
+FROM %[5]s H
+%[7]s
+WHERE %[8]s`, key, label, sign, tableDef.DocType, tableDef.Header, tableDef.Lines, group.Joins, whereClause))
+	}
+
+	order := "groupKey ASC"
+	if !group.Ordered {
+		order = "amount DESC, groupKey ASC"
+	}
+
+	return sqlQuery{
+		Query: fmt.Sprintf(`SELECT U.groupKey, MAX(U.groupLabel), SUM(U.amount) AS amount, SUM(U.vatSum), SUM(U.quantity), COUNT(DISTINCT %s)
+FROM (%s) U
+GROUP BY U.groupKey
+ORDER BY %s`, documentID, strings.Join(parts, "\nUNION ALL\n"), order),
+		Args: args,
+	}, nil
+}
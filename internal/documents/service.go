@@ -1,8 +1,11 @@
 package documents
 
 import (
+	"bytes"
 	"context"
-	"fmt"
+	"time"
+
+	"sql-service/pkg/apperr"
 )
 
 type DocumentService struct {
@@ -15,39 +18,156 @@ func NewDocumentService(repo *DocumentRrepository) *DocumentService {
 	}
 }
 
-func (service *DocumentService) DocumentServiceHandler(dto *CartessetDto) []Cartesset {
-	result, err := service.documentRrepository.GetCartesset(dto)
+func (service *DocumentService) DocumentServiceHandler(ctx context.Context, dto *CartessetDto) ([]Cartesset, error) {
+	result, err := service.documentRrepository.GetCartesset(ctx, dto)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return []Cartesset{}, nil
+	}
+	return result, nil
+}
+
+func (service *DocumentService) CartessetPDF(ctx context.Context, dto *CartessetDto, opts statementPdfOptions) ([]byte, error) {
+	header, err := service.documentRrepository.GetCustomerHeader(ctx, dto.CardCode)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, apperr.NotFound("customer not found")
+	}
+
+	rows, err := service.documentRrepository.GetCartesset(ctx, dto)
 	if err != nil {
-		fmt.Println("error", err.Error())
-		return []Cartesset{}
+		return nil, err
 	}
-	return result
+
+	opts.DateFrom = dto.DateFrom
+	opts.DateTo = dto.DateTo
+
+	var buf bytes.Buffer
+	if err := renderCartessetPDF(&buf, header, rows, opts); err != nil {
+		return nil, apperr.Internal("failed to render statement", err)
+	}
+	return buf.Bytes(), nil
 }
 
-func (service *DocumentService) OpenProducts(dto *AllProductsDto) []OpenProducts {
-	result, err := service.documentRrepository.GetOpenProducts(dto)
+func (service *DocumentService) OpenProducts(ctx context.Context, dto *AllProductsDto) ([]OpenProducts, error) {
+	result, err := service.documentRrepository.GetOpenProducts(ctx, dto)
 	if err != nil {
-		fmt.Println("error", err.Error())
-		return []OpenProducts{}
+		return nil, err
 	}
 	if result == nil {
-		return []OpenProducts{}
+		return []OpenProducts{}, nil
 	}
-	return result
+	if dto.Grouped {
+		return groupOpenProducts(result), nil
+	}
+	return result, nil
 }
 
-func (service *DocumentService) Hovot(dto *HovotDto) []Hovot {
-	result, err := service.documentRrepository.GetHovot(dto)
+func (service *DocumentService) Hovot(ctx context.Context, dto *HovotDto) ([]Hovot, error) {
+	result, err := service.documentRrepository.GetHovot(ctx, dto)
 	if err != nil {
-		fmt.Println("error", err.Error())
-		return []Hovot{}
+		return nil, err
 	}
 	if result == nil {
-		return []Hovot{}
+		return []Hovot{}, nil
 	}
-	return result
+	return result, nil
+}
+
+func (service *DocumentService) Aging(ctx context.Context, dto *AgingDto, asOf time.Time) (AgingReport, error) {
+	var (
+		lines []HovotLine
+		err   error
+	)
+	if dto.SlpCode != nil {
+		lines, err = service.documentRrepository.GetHovotBySlpCode(ctx, *dto.SlpCode)
+	} else {
+		lines, err = service.documentRrepository.GetHovotByCardCode(ctx, dto.CardCode)
+	}
+	if err != nil {
+		return AgingReport{}, err
+	}
+
+	report := buildAgingReport(lines, asOf, dateOnly(time.Now().UTC()))
+	report.CardCode = dto.CardCode
+	report.SlpCode = dto.SlpCode
+	return report, nil
 }
 
 func (service *DocumentService) GetSapDocuments(ctx context.Context, query SapDocumentsQuery) (SapDocumentsResponse, error) {
 	return service.documentRrepository.GetSapDocuments(ctx, query)
 }
+
+func (service *DocumentService) GetSapDocument(ctx context.Context, docType string, docEntry int64) (map[string]any, error) {
+	return service.documentRrepository.GetSapDocument(ctx, docType, docEntry)
+}
+
+func (service *DocumentService) GetSapDocumentAttachments(ctx context.Context, docType string, docEntry int64) ([]SapAttachment, error) {
+	return service.documentRrepository.GetSapDocumentAttachments(ctx, docType, docEntry)
+}
+
+// GetSapDocumentAttachment finds one attachment line of a document.
+func (service *DocumentService) GetSapDocumentAttachment(ctx context.Context, docType string, docEntry int64, line int) (SapAttachment, error) {
+	attachments, err := service.documentRrepository.GetSapDocumentAttachments(ctx, docType, docEntry)
+	if err != nil {
+		return SapAttachment{}, err
+	}
+	for _, attachment := range attachments {
+		if attachment.Line == line {
+			return attachment, nil
+		}
+	}
+	return SapAttachment{}, apperr.NotFound("attachment not found")
+}
+
+func (service *DocumentService) GetSapDocumentsSummary(ctx context.Context, query SapDocumentsQuery, groupBy string) (SapSummaryResponse, error) {
+	rows, err := service.documentRrepository.GetSapDocumentsSummary(ctx, query, groupBy)
+	if err != nil {
+		return SapSummaryResponse{}, err
+	}
+
+	var amount float64
+	total := SapSummaryRow{Key: "total"}
+	for i := range rows {
+		for _, value := range []*float64{rows[i].DocTotal, rows[i].LineTotal} {
+			if value != nil {
+				*value = roundAmount(*value)
+				amount += *value
+			}
+		}
+		rows[i].VatSum = roundAmount(rows[i].VatSum)
+		rows[i].Quantity = roundAmount(rows[i].Quantity)
+		total.VatSum += rows[i].VatSum
+		total.Quantity += rows[i].Quantity
+	}
+	amount = roundAmount(amount)
+	if sapSummaryGroups[groupBy].LineLevel {
+		total.LineTotal = &amount
+	} else {
+		total.DocTotal = &amount
+	}
+	total.VatSum = roundAmount(total.VatSum)
+	total.Quantity = roundAmount(total.Quantity)
+
+	// a document spreads over several item groups or warehouses, so the
+	// document count of the total cannot be summed from the rows
+	total.Documents, err = service.documentRrepository.CountSapDocuments(ctx, query)
+	if err != nil {
+		return SapSummaryResponse{}, err
+	}
+
+	return SapSummaryResponse{
+		GroupBy:  groupBy,
+		DocTypes: query.DocTypes,
+		Rows:     rows,
+		Total:    total,
+	}, nil
+}
+
+func (service *DocumentService) GetSapDocumentChain(ctx context.Context, docType string, docEntry int64) (SapDocumentChain, error) {
+	return service.documentRrepository.GetSapDocumentChain(ctx, docType, docEntry)
+}
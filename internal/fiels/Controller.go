@@ -57,30 +57,7 @@ func (controller *FielsController) GetImage() http.HandlerFunc {
 		}
 
 		folderPath := controller.Config.ImagesPath
-		filePath := filepath.Join(folderPath, fileName)
-
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			res.Json(w, map[string]interface{}{"error": "Image not found"}, http.StatusNotFound)
-			return
-		}
-
-		ext := strings.ToLower(filepath.Ext(fileName))
-		switch ext {
-		case ".jpg", ".jpeg":
-			w.Header().Set("Content-Type", "image/jpeg")
-		case ".png":
-			w.Header().Set("Content-Type", "image/png")
-		case ".gif":
-			w.Header().Set("Content-Type", "image/gif")
-		case ".bmp":
-			w.Header().Set("Content-Type", "image/bmp")
-		default:
-			res.Json(w, map[string]interface{}{"error": "Unsupported image format"}, http.StatusUnsupportedMediaType)
-			return
-		}
-
-		w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
-		http.ServeFile(w, r, filePath)
+		controller.serveImage(w, r, folderPath, fileName)
 	}
 }
 
@@ -106,29 +83,29 @@ func (controller *FielsController) GetProductLineArt() http.HandlerFunc {
 		}
 
 		folderPath := controller.Config.ProductLineArtsPath
-		filePath := filepath.Join(folderPath, fileName)
+		controller.serveImage(w, r, folderPath, fileName)
+	}
+}
 
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
-			res.Json(w, map[string]interface{}{"error": "Image not found"}, http.StatusNotFound)
-			return
-		}
+func (controller *FielsController) serveImage(w http.ResponseWriter, r *http.Request, folderPath, fileName string) {
+	filePath, err := SafeJoin(folderPath, fileName)
+	if err != nil {
+		res.Json(w, map[string]interface{}{"error": "Invalid file name"}, http.StatusBadRequest)
+		return
+	}
 
-		ext := strings.ToLower(filepath.Ext(fileName))
-		switch ext {
-		case ".jpg", ".jpeg":
-			w.Header().Set("Content-Type", "image/jpeg")
-		case ".png":
-			w.Header().Set("Content-Type", "image/png")
-		case ".gif":
-			w.Header().Set("Content-Type", "image/gif")
-		case ".bmp":
-			w.Header().Set("Content-Type", "image/bmp")
-		default:
-			res.Json(w, map[string]interface{}{"error": "Unsupported image format"}, http.StatusUnsupportedMediaType)
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".bmp":
+	default:
+		res.Json(w, map[string]interface{}{"error": "Unsupported image format"}, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if err := ServeFile(w, r, filePath, filepath.Base(filePath)); err != nil {
+		if os.IsNotExist(err) {
+			res.Json(w, map[string]interface{}{"error": "Image not found"}, http.StatusNotFound)
 			return
 		}
-
-		w.Header().Set("Content-Disposition", "attachment; filename="+fileName)
-		http.ServeFile(w, r, filePath)
+		res.Json(w, map[string]interface{}{"error": err.Error()}, http.StatusInternalServerError)
 	}
 }
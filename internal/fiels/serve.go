@@ -0,0 +1,76 @@
+package fiels
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsafePath = errors.New("path escapes the root folder")
+
+// SafeJoin joins a client supplied relative name onto root and refuses
+// anything that would land outside of it (absolute paths, "..", volumes).
+// Drive letters are refused on every OS, the names often come from Windows.
+func SafeJoin(root, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if name == "" || strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" || hasDriveLetter(name) {
+		return "", ErrUnsafePath
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", ErrUnsafePath
+		}
+	}
+
+	joined := filepath.Join(root, filepath.FromSlash(name))
+	rel, err := filepath.Rel(filepath.Clean(root), joined)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", ErrUnsafePath
+	}
+	return joined, nil
+}
+
+func hasDriveLetter(name string) bool {
+	if len(name) < 2 || name[1] != ':' {
+		return false
+	}
+	c := name[0] | 0x20
+	return c >= 'a' && c <= 'z'
+}
+
+// ServeFile streams a file as a download. The content type comes from the
+// extension, or from sniffing the first bytes when the extension is unknown.
+func ServeFile(w http.ResponseWriter, r *http.Request, filePath, downloadName string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return os.ErrNotExist
+	}
+
+	contentType := mime.TypeByExtension(strings.ToLower(filepath.Ext(downloadName)))
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(file, head)
+		contentType = http.DetectContentType(head[:n])
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": downloadName}))
+	http.ServeContent(w, r, downloadName, info.ModTime(), file)
+	return nil
+}
@@ -0,0 +1,52 @@
+package fiels
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.FromSlash("/srv/files")
+
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{name: "plain file", file: "report.pdf", want: "/srv/files/report.pdf"},
+		{name: "sub folder", file: "2024/report.pdf", want: "/srv/files/2024/report.pdf"},
+		{name: "backslash separated", file: `2024\report.pdf`, want: "/srv/files/2024/report.pdf"},
+		{name: "dot segment", file: "./report.pdf", want: "/srv/files/report.pdf"},
+		{name: "dots inside a name", file: "report..pdf", want: "/srv/files/report..pdf"},
+		{name: "empty", file: ""},
+		{name: "root itself", file: "."},
+		{name: "parent", file: ".."},
+		{name: "parent prefix", file: "../secret.txt"},
+		{name: "parent in the middle", file: "2024/../../secret.txt"},
+		{name: "backslash parent", file: `2024\..\..\secret.txt`},
+		{name: "absolute", file: "/etc/passwd"},
+		{name: "backslash absolute", file: `\Windows\win.ini`},
+		{name: "UNC path", file: `\\server\share\file.txt`},
+		{name: "drive letter", file: `C:\Windows\win.ini`},
+		{name: "drive relative", file: "c:secret.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SafeJoin(root, tt.file)
+			if tt.want == "" {
+				if !errors.Is(err, ErrUnsafePath) {
+					t.Fatalf("SafeJoin(%q) = %q, %v, want ErrUnsafePath", tt.file, got, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SafeJoin(%q) failed: %v", tt.file, err)
+			}
+			if want := filepath.FromSlash(tt.want); got != want {
+				t.Errorf("SafeJoin(%q) = %q, want %q", tt.file, got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package items
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-service/configs"
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/req"
+	"sql-service/pkg/res"
+)
+
+type ItemControllerDeps struct {
+	*configs.Config
+	*ItemService
+}
+
+type ItemController struct {
+	*configs.Config
+	*ItemService
+}
+
+func NewItemController(router *http.ServeMux, deps ItemControllerDeps) *ItemController {
+	controller := &ItemController{
+		Config:      deps.Config,
+		ItemService: deps.ItemService,
+	}
+
+	router.Handle("GET /items", controller.GetItems())
+	// item codes may contain "/", the rest of the path is the code
+	router.Handle("GET /items/{code...}", controller.GetItem())
+
+	return controller
+}
+
+func (Controller *ItemController) GetItems() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqStart := time.Now()
+		log.Printf("[/items] start")
+
+		query, err := parseItemsQuery(r.URL.Query())
+		if err != nil {
+			log.Printf("[/items] invalid query: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
+
+		response, err := Controller.ItemService.SearchItems(r.Context(), *query)
+		if err != nil {
+			log.Printf("[/items] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
+		log.Printf("[/items] service done (elapsed=%s), rows=%d, total=%d", time.Since(reqStart), len(response.Items), response.Total)
+
+		res.Json(w, response, http.StatusOK)
+		log.Printf("[/items] response sent (total=%s)", time.Since(reqStart))
+	}
+}
+
+func (Controller *ItemController) GetItem() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqStart := time.Now()
+		log.Printf("[/items/{code}] start")
+
+		code := strings.TrimSpace(r.PathValue("code"))
+		if code == "" {
+			res.Error(w, apperr.Invalid("code is required"))
+			return
+		}
+
+		item, err := Controller.ItemService.GetItem(r.Context(), code)
+		if err != nil {
+			log.Printf("[/items/{code}] service failed: %v (elapsed=%s), code=%s", err, time.Since(reqStart), code)
+			res.Error(w, err)
+			return
+		}
+		log.Printf("[/items/{code}] service done (elapsed=%s), code=%s", time.Since(reqStart), code)
+
+		res.Json(w, item, http.StatusOK)
+		log.Printf("[/items/{code}] response sent (total=%s)", time.Since(reqStart))
+	}
+}
+
+// parseItemsQuery reads the search parameters; every U_* parameter filters
+// on the user field of that name.
+func parseItemsQuery(values url.Values) (*ItemsQuery, error) {
+	query := &ItemsQuery{UserFields: map[string]string{}}
+
+	if value := strings.TrimSpace(values.Get("q")); value != "" {
+		query.Text = &value
+	}
+
+	var err error
+	if query.ItmsGrpCod, err = req.OptionalInt(values.Get("itmsGrpCod"), "itmsGrpCod"); err != nil {
+		return nil, err
+	}
+	if query.FirmCode, err = req.OptionalInt(values.Get("firmCode"), "firmCode"); err != nil {
+		return nil, err
+	}
+
+	if value := strings.ToLower(strings.TrimSpace(values.Get("status"))); value != "" && value != "all" {
+		if value != itemStatusActive && value != itemStatusFrozen {
+			return nil, apperr.Validation("invalid status", "status must be active, frozen or all")
+		}
+		query.Status = &value
+	}
+
+	if query.SellItem, err = optionalBoolParam(values.Get("sellItem"), "sellItem"); err != nil {
+		return nil, err
+	}
+	if query.PrchseItem, err = optionalBoolParam(values.Get("prchseItem"), "prchseItem"); err != nil {
+		return nil, err
+	}
+
+	for name, list := range values {
+		if !strings.HasPrefix(strings.ToUpper(name), "U_") {
+			continue
+		}
+		if !req.IsIdentifier(name) {
+			return nil, apperr.Validation("invalid user field", name+" is not a valid field name")
+		}
+		query.UserFields[name] = list[0]
+	}
+
+	query.SortBy = itemSortItemCode
+	if value := strings.TrimSpace(values.Get("sortBy")); value != "" {
+		found := false
+		for name := range itemSortColumns {
+			if strings.EqualFold(name, value) {
+				query.SortBy = name
+				found = true
+			}
+		}
+		if !found {
+			return nil, apperr.Validation("invalid sortBy", "sortBy must be itemCode, itemName or updateDate")
+		}
+	}
+
+	switch value := strings.ToLower(strings.TrimSpace(values.Get("sortDir"))); value {
+	case "", "asc":
+		query.SortDir = "asc"
+	case "desc":
+		query.SortDir = "desc"
+	default:
+		return nil, apperr.Validation("invalid sortDir", "sortDir must be asc or desc")
+	}
+
+	query.Page = 1
+	if value := strings.TrimSpace(values.Get("page")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			return nil, apperr.Validation("invalid page", "page must be an integer >= 1")
+		}
+		query.Page = parsed
+	}
+
+	query.PageSize = 50
+	if value := strings.TrimSpace(values.Get("pageSize")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 200 {
+			return nil, apperr.Validation("invalid pageSize", "pageSize must be an integer between 1 and 200")
+		}
+		query.PageSize = parsed
+	}
+
+	return query, nil
+}
+
+func optionalBoolParam(value, name string) (*bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, apperr.Validation("invalid "+name, name+" must be true or false")
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,90 @@
+package items
+
+import "time"
+
+// ItemsQuery is the parsed query string of GET /items. UserFields maps OITM
+// user defined fields (U_*) to the value they must equal.
+type ItemsQuery struct {
+	Text       *string
+	ItmsGrpCod *int
+	FirmCode   *int
+	Status     *string
+	SellItem   *bool
+	PrchseItem *bool
+	UserFields map[string]string
+	SortBy     string
+	SortDir    string
+	Page       int
+	PageSize   int
+}
+
+type ItemsResponse struct {
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+	Total    int           `json:"total"`
+	Items    []ItemSummary `json:"items"`
+}
+
+type ItemSummary struct {
+	ItemCode   string     `json:"itemCode"`
+	ItemName   *string    `json:"itemName"`
+	FrgnName   *string    `json:"frgnName"`
+	ItmsGrpCod *int       `json:"itmsGrpCod"`
+	ItmsGrpNam *string    `json:"itmsGrpNam"`
+	FirmCode   *int       `json:"firmCode"`
+	FirmName   *string    `json:"firmName"`
+	Frozen     bool       `json:"frozen"`
+	SellItem   bool       `json:"sellItem"`
+	PrchseItem bool       `json:"prchseItem"`
+	InvntItem  bool       `json:"invntItem"`
+	CodeBars   *string    `json:"codeBars"`
+	UpdateDate *time.Time `json:"updateDate"`
+	Image      *ItemImage `json:"image"`
+}
+
+// Item is the master data of GET /items/{code}.
+type Item struct {
+	ItemSummary
+	SalUnitMsr *string        `json:"salUnitMsr"`
+	BuyUnitMsr *string        `json:"buyUnitMsr"`
+	InvntryUom *string        `json:"invntryUom"`
+	NumInSale  *float64       `json:"numInSale"`
+	NumInBuy   *float64       `json:"numInBuy"`
+	ManBtchNum bool           `json:"manBtchNum"`
+	ManSerNum  bool           `json:"manSerNum"`
+	CreateDate *time.Time     `json:"createDate"`
+	UomGroup   *UomGroup      `json:"uomGroup"`
+	Barcodes   []ItemBarcode  `json:"barcodes"`
+	UserFields map[string]any `json:"userFields"`
+}
+
+// ItemImage is the OITM picture, served by the GET /image/ endpoint.
+type ItemImage struct {
+	File string `json:"file"`
+	URL  string `json:"url"`
+}
+
+type ItemBarcode struct {
+	BcdEntry int     `json:"bcdEntry"`
+	BcdCode  string  `json:"bcdCode"`
+	BcdName  *string `json:"bcdName"`
+	UomEntry *int    `json:"uomEntry"`
+	UomCode  *string `json:"uomCode"`
+}
+
+type UomGroup struct {
+	UgpEntry    int             `json:"ugpEntry"`
+	UgpCode     string          `json:"ugpCode"`
+	UgpName     *string         `json:"ugpName"`
+	BaseUom     *int            `json:"baseUom"`
+	BaseUomCode *string         `json:"baseUomCode"`
+	Conversions []UomConversion `json:"conversions"`
+}
+
+// UomConversion is one UGP1 line: AltQty of the unit equal BaseQty base units.
+type UomConversion struct {
+	UomEntry int     `json:"uomEntry"`
+	UomCode  *string `json:"uomCode"`
+	AltQty   float64 `json:"altQty"`
+	BaseQty  float64 `json:"baseQty"`
+}
@@ -0,0 +1,376 @@
+package items
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/db"
+)
+
+type ItemRepository struct{ Db *db.Db }
+
+func NewItemRepository(db *db.Db) *ItemRepository { return &ItemRepository{Db: db} }
+
+const itemSummaryColumns = `T0.ItemCode, T0.ItemName, T0.FrgnName, T0.ItmsGrpCod, T1.ItmsGrpNam,
+       T0.FirmCode, T2.FirmName, T0.validFor, T0.frozenFor, T0.SellItem, T0.PrchseItem,
+       T0.InvntItem, T0.CodeBars, T0.UpdateDate, T0.PicturName`
+
+const itemFrom = `FROM OITM T0
+LEFT JOIN OITB T1 ON T1.ItmsGrpCod = T0.ItmsGrpCod
+LEFT JOIN OMRC T2 ON T2.FirmCode = T0.FirmCode`
+
+func itemFilters(dialect string, query ItemsQuery) []db.Filter {
+	filters := make([]db.Filter, 0)
+	if query.Text != nil && *query.Text != "" {
+		filters = append(filters, db.Filter{
+			Name:   "text",
+			Value:  "%" + db.EscapeLike(*query.Text) + "%",
+			Clause: `(T0.ItemCode LIKE {p} ESCAPE '\' OR T0.ItemName LIKE {p} ESCAPE '\' OR T0.FrgnName LIKE {p} ESCAPE '\')`,
+		})
+	}
+	if query.ItmsGrpCod != nil {
+		filters = append(filters, db.Filter{Name: "itmsGrpCod", Value: *query.ItmsGrpCod, Clause: "T0.ItmsGrpCod = {p}"})
+	}
+	if query.FirmCode != nil {
+		filters = append(filters, db.Filter{Name: "firmCode", Value: *query.FirmCode, Clause: "T0.FirmCode = {p}"})
+	}
+	// an item is active when it is flagged active (validFor) and not
+	// inactive (frozenFor), every other item counts as frozen
+	if query.Status != nil {
+		clause := "(T0.validFor = {p} AND T0.frozenFor <> {p})"
+		if *query.Status == itemStatusFrozen {
+			clause = "(T0.validFor <> {p} OR T0.frozenFor = {p})"
+		}
+		filters = append(filters, db.Filter{Name: "status", Value: "Y", Clause: clause})
+	}
+	if query.SellItem != nil {
+		filters = append(filters, db.Filter{Name: "sellItem", Value: yesNo(*query.SellItem), Clause: "T0.SellItem = {p}"})
+	}
+	if query.PrchseItem != nil {
+		filters = append(filters, db.Filter{Name: "prchseItem", Value: yesNo(*query.PrchseItem), Clause: "T0.PrchseItem = {p}"})
+	}
+
+	// UserFields holds column names already checked against the OITM schema
+	names := make([]string, 0, len(query.UserFields))
+	for name := range query.UserFields {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for i, name := range names {
+		filters = append(filters, db.Filter{
+			Name:   fmt.Sprintf("udf%d", i),
+			Value:  query.UserFields[name],
+			Clause: "T0." + db.QuoteIdentifier(dialect, name) + " = {p}",
+		})
+	}
+	return filters
+}
+
+func (r *ItemRepository) SearchItems(ctx context.Context, query ItemsQuery) (ItemsResponse, error) {
+	whereClause, args, err := db.BindFilters(r.Db.Dialect, itemFilters(r.Db.Dialect, query))
+	if err != nil {
+		return ItemsResponse{}, err
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(1) %s\nWHERE %s", itemFrom, whereClause)
+	if err := r.Db.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
+		return ItemsResponse{}, apperr.Upstream("failed to count items", err)
+	}
+
+	offset := (query.Page - 1) * query.PageSize
+	pageArgs := append([]any{}, args...)
+	var paging string
+	switch strings.ToLower(r.Db.Dialect) {
+	case "", "mssql":
+		paging = "OFFSET @offset ROWS FETCH NEXT @pageSize ROWS ONLY"
+		pageArgs = append(pageArgs, sql.Named("offset", offset), sql.Named("pageSize", query.PageSize))
+	case "hana":
+		paging = "LIMIT ? OFFSET ?"
+		pageArgs = append(pageArgs, query.PageSize, offset)
+	default:
+		return ItemsResponse{}, fmt.Errorf("unsupported db dialect: %s", r.Db.Dialect)
+	}
+
+	direction := strings.ToUpper(query.SortDir)
+	orderClause := fmt.Sprintf("%s %s, T0.ItemCode %s", itemSortColumns[query.SortBy], direction, direction)
+	if query.SortBy == itemSortItemCode {
+		orderClause = "T0.ItemCode " + direction
+	}
+
+	selectQuery := fmt.Sprintf("SELECT %s\n%s\nWHERE %s\nORDER BY %s %s", itemSummaryColumns, itemFrom, whereClause, orderClause, paging)
+	rows, err := r.Db.QueryContext(ctx, selectQuery, pageArgs...)
+	if err != nil {
+		return ItemsResponse{}, apperr.Upstream("failed to fetch items", err)
+	}
+	defer rows.Close()
+
+	items := make([]ItemSummary, 0, query.PageSize)
+	for rows.Next() {
+		var item ItemSummary
+		scanned := newItemSummaryScan(&item)
+		if err := rows.Scan(scanned.dest()...); err != nil {
+			return ItemsResponse{}, apperr.Upstream("failed to fetch items", err)
+		}
+		scanned.apply()
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return ItemsResponse{}, apperr.Upstream("failed to fetch items", err)
+	}
+
+	return ItemsResponse{
+		Page:     query.Page,
+		PageSize: query.PageSize,
+		Total:    total,
+		Items:    items,
+	}, nil
+}
+
+// GetItem loads the master data of code with the given user defined fields.
+func (r *ItemRepository) GetItem(ctx context.Context, code string, userFields []string) (Item, error) {
+	whereClause, args, err := db.BindFilters(r.Db.Dialect, []db.Filter{{Name: "itemCode", Value: code, Clause: "T0.ItemCode = {p}"}})
+	if err != nil {
+		return Item{}, err
+	}
+
+	udfColumns := ""
+	for _, name := range userFields {
+		udfColumns += ", T0." + db.QuoteIdentifier(r.Db.Dialect, name)
+	}
+	query := fmt.Sprintf(`SELECT %s,
+       T0.SalUnitMsr, T0.BuyUnitMsr, T0.InvntryUom, T0.NumInSale, T0.NumInBuy,
+       T0.ManBtchNum, T0.ManSerNum, T0.CreateDate, T0.UgpEntry%s
+%s
+WHERE %s`, itemSummaryColumns, udfColumns, itemFrom, whereClause)
+
+	var (
+		item       Item
+		salUnitMsr sql.NullString
+		buyUnitMsr sql.NullString
+		invntryUom sql.NullString
+		numInSale  sql.NullFloat64
+		numInBuy   sql.NullFloat64
+		manBtchNum sql.NullString
+		manSerNum  sql.NullString
+		createDate sql.NullTime
+		ugpEntry   sql.NullInt64
+	)
+	scanned := newItemSummaryScan(&item.ItemSummary)
+	udfValues := make([]any, len(userFields))
+	dest := append(scanned.dest(), &salUnitMsr, &buyUnitMsr, &invntryUom, &numInSale, &numInBuy, &manBtchNum, &manSerNum, &createDate, &ugpEntry)
+	for i := range udfValues {
+		dest = append(dest, &udfValues[i])
+	}
+
+	err = r.Db.QueryRowContext(ctx, query, args...).Scan(dest...)
+	if err == sql.ErrNoRows {
+		return Item{}, apperr.NotFound("item not found")
+	}
+	if err != nil {
+		return Item{}, apperr.Upstream("failed to fetch item", err)
+	}
+	scanned.apply()
+
+	item.SalUnitMsr = db.StringPtr(salUnitMsr)
+	item.BuyUnitMsr = db.StringPtr(buyUnitMsr)
+	item.InvntryUom = db.StringPtr(invntryUom)
+	item.NumInSale = db.FloatPtr(numInSale)
+	item.NumInBuy = db.FloatPtr(numInBuy)
+	item.ManBtchNum = manBtchNum.String == "Y"
+	item.ManSerNum = manSerNum.String == "Y"
+	if createDate.Valid {
+		item.CreateDate = &createDate.Time
+	}
+	item.UserFields = make(map[string]any, len(userFields))
+	for i, name := range userFields {
+		value := udfValues[i]
+		if raw, ok := value.([]byte); ok {
+			value = string(raw)
+		}
+		item.UserFields[name] = value
+	}
+
+	if item.Barcodes, err = r.getItemBarcodes(ctx, item.ItemCode); err != nil {
+		return Item{}, err
+	}
+	// UgpEntry -1 is the manual group, it has no OUGP row
+	if ugpEntry.Valid && ugpEntry.Int64 > 0 {
+		if item.UomGroup, err = r.getUomGroup(ctx, int(ugpEntry.Int64)); err != nil {
+			return Item{}, err
+		}
+	}
+	return item, nil
+}
+
+func (r *ItemRepository) getItemBarcodes(ctx context.Context, code string) ([]ItemBarcode, error) {
+	whereClause, args, err := db.BindFilters(r.Db.Dialect, []db.Filter{{Name: "itemCode", Value: code, Clause: "B.ItemCode = {p}"}})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.Db.QueryContext(ctx, fmt.Sprintf(`
+SELECT B.BcdEntry, B.BcdCode, B.BcdName, B.UomEntry, U.UomCode
+FROM OBCD B
+LEFT JOIN OUOM U ON U.UomEntry = B.UomEntry
+WHERE %s
+ORDER BY B.BcdEntry`, whereClause), args...)
+	if err != nil {
+		return nil, apperr.Upstream("failed to fetch item barcodes", err)
+	}
+	defer rows.Close()
+
+	barcodes := make([]ItemBarcode, 0)
+	for rows.Next() {
+		var (
+			barcode  ItemBarcode
+			bcdName  sql.NullString
+			uomEntry sql.NullInt64
+			uomCode  sql.NullString
+		)
+		if err := rows.Scan(&barcode.BcdEntry, &barcode.BcdCode, &bcdName, &uomEntry, &uomCode); err != nil {
+			return nil, apperr.Upstream("failed to fetch item barcodes", err)
+		}
+		barcode.BcdName = db.StringPtr(bcdName)
+		barcode.UomEntry = db.IntPtr(uomEntry)
+		barcode.UomCode = db.StringPtr(uomCode)
+		barcodes = append(barcodes, barcode)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("failed to fetch item barcodes", err)
+	}
+	return barcodes, nil
+}
+
+func (r *ItemRepository) getUomGroup(ctx context.Context, ugpEntry int) (*UomGroup, error) {
+	headerWhere, headerArgs, err := db.BindFilters(r.Db.Dialect, []db.Filter{{Name: "ugpEntry", Value: ugpEntry, Clause: "G.UgpEntry = {p}"}})
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		group       UomGroup
+		ugpName     sql.NullString
+		baseUom     sql.NullInt64
+		baseUomCode sql.NullString
+	)
+	err = r.Db.QueryRowContext(ctx, fmt.Sprintf(`
+SELECT G.UgpEntry, G.UgpCode, G.UgpName, G.BaseUom, U.UomCode
+FROM OUGP G
+LEFT JOIN OUOM U ON U.UomEntry = G.BaseUom
+WHERE %s`, headerWhere), headerArgs...).Scan(&group.UgpEntry, &group.UgpCode, &ugpName, &baseUom, &baseUomCode)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apperr.Upstream("failed to fetch uom group", err)
+	}
+	group.UgpName = db.StringPtr(ugpName)
+	group.BaseUom = db.IntPtr(baseUom)
+	group.BaseUomCode = db.StringPtr(baseUomCode)
+
+	linesWhere, linesArgs, err := db.BindFilters(r.Db.Dialect, []db.Filter{{Name: "ugpEntry", Value: ugpEntry, Clause: "L.UgpEntry = {p}"}})
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.Db.QueryContext(ctx, fmt.Sprintf(`
+SELECT L.UomEntry, U.UomCode, L.AltQty, L.BaseQty
+FROM UGP1 L
+LEFT JOIN OUOM U ON U.UomEntry = L.UomEntry
+WHERE %s
+ORDER BY L.LineNum`, linesWhere), linesArgs...)
+	if err != nil {
+		return nil, apperr.Upstream("failed to fetch uom group", err)
+	}
+	defer rows.Close()
+
+	group.Conversions = make([]UomConversion, 0)
+	for rows.Next() {
+		var (
+			conversion UomConversion
+			uomCode    sql.NullString
+		)
+		if err := rows.Scan(&conversion.UomEntry, &uomCode, &conversion.AltQty, &conversion.BaseQty); err != nil {
+			return nil, apperr.Upstream("failed to fetch uom group", err)
+		}
+		conversion.UomCode = db.StringPtr(uomCode)
+		group.Conversions = append(group.Conversions, conversion)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("failed to fetch uom group", err)
+	}
+	return &group, nil
+}
+
+// itemSummaryScan holds the nullable columns of itemSummaryColumns until they
+// are copied onto the item.
+type itemSummaryScan struct {
+	item       *ItemSummary
+	itemName   sql.NullString
+	frgnName   sql.NullString
+	itmsGrpCod sql.NullInt64
+	itmsGrpNam sql.NullString
+	firmCode   sql.NullInt64
+	firmName   sql.NullString
+	validFor   sql.NullString
+	frozenFor  sql.NullString
+	sellItem   sql.NullString
+	prchseItem sql.NullString
+	invntItem  sql.NullString
+	codeBars   sql.NullString
+	updateDate sql.NullTime
+	picturName sql.NullString
+}
+
+func newItemSummaryScan(item *ItemSummary) *itemSummaryScan {
+	return &itemSummaryScan{item: item}
+}
+
+func (s *itemSummaryScan) dest() []any {
+	return []any{
+		&s.item.ItemCode, &s.itemName, &s.frgnName, &s.itmsGrpCod, &s.itmsGrpNam,
+		&s.firmCode, &s.firmName, &s.validFor, &s.frozenFor, &s.sellItem, &s.prchseItem,
+		&s.invntItem, &s.codeBars, &s.updateDate, &s.picturName,
+	}
+}
+
+func (s *itemSummaryScan) apply() {
+	s.item.ItemName = db.StringPtr(s.itemName)
+	s.item.FrgnName = db.StringPtr(s.frgnName)
+	s.item.ItmsGrpCod = db.IntPtr(s.itmsGrpCod)
+	s.item.ItmsGrpNam = db.StringPtr(s.itmsGrpNam)
+	s.item.FirmCode = db.IntPtr(s.firmCode)
+	s.item.FirmName = db.StringPtr(s.firmName)
+	s.item.Frozen = s.validFor.String != "Y" || s.frozenFor.String == "Y"
+	s.item.SellItem = s.sellItem.String == "Y"
+	s.item.PrchseItem = s.prchseItem.String == "Y"
+	s.item.InvntItem = s.invntItem.String == "Y"
+	s.item.CodeBars = db.StringPtr(s.codeBars)
+	if s.updateDate.Valid {
+		updateDate := s.updateDate.Time
+		s.item.UpdateDate = &updateDate
+	}
+	s.item.Image = itemImage(s.picturName)
+}
+
+// itemImage links the OITM picture file to the GET /image/ endpoint of the
+// fiels package, which serves it from the images folder.
+func itemImage(picturName sql.NullString) *ItemImage {
+	file := strings.TrimSpace(picturName.String)
+	if file == "" {
+		return nil
+	}
+	return &ItemImage{File: file, URL: "/image/" + url.PathEscape(file)}
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "Y"
+	}
+	return "N"
+}
@@ -0,0 +1,91 @@
+package items
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"sql-service/pkg/apperr"
+)
+
+const (
+	itemStatusActive = "active"
+	itemStatusFrozen = "frozen"
+
+	itemSortItemCode   = "itemCode"
+	itemSortItemName   = "itemName"
+	itemSortUpdateDate = "updateDate"
+)
+
+var itemSortColumns = map[string]string{
+	itemSortItemCode:   "T0.ItemCode",
+	itemSortItemName:   "T0.ItemName",
+	itemSortUpdateDate: "T0.UpdateDate",
+}
+
+type ItemService struct {
+	itemRepository *ItemRepository
+}
+
+func NewItemService(repo *ItemRepository) *ItemService {
+	return &ItemService{
+		itemRepository: repo,
+	}
+}
+
+// SearchItems checks the user field filters against the OITM schema before
+// running the search, so that only real column names reach the query.
+func (service *ItemService) SearchItems(ctx context.Context, query ItemsQuery) (ItemsResponse, error) {
+	if len(query.UserFields) > 0 {
+		columns, err := service.userFieldColumns(ctx)
+		if err != nil {
+			return ItemsResponse{}, err
+		}
+
+		resolved := make(map[string]string, len(query.UserFields))
+		for name, value := range query.UserFields {
+			column, ok := findColumn(columns, name)
+			if !ok {
+				return ItemsResponse{}, apperr.Validation("unknown user field", fmt.Sprintf("OITM has no user field %s", name))
+			}
+			resolved[column] = value
+		}
+		query.UserFields = resolved
+	}
+
+	return service.itemRepository.SearchItems(ctx, query)
+}
+
+// GetItem returns the master data of code with all of its OITM user fields.
+func (service *ItemService) GetItem(ctx context.Context, code string) (Item, error) {
+	columns, err := service.userFieldColumns(ctx)
+	if err != nil {
+		return Item{}, err
+	}
+	return service.itemRepository.GetItem(ctx, code, columns)
+}
+
+// userFieldColumns lists the U_ columns of OITM.
+func (service *ItemService) userFieldColumns(ctx context.Context) ([]string, error) {
+	columns, err := service.itemRepository.Db.TableColumns(ctx, "OITM")
+	if err != nil {
+		return nil, apperr.Upstream("failed to read OITM columns", err)
+	}
+
+	userFields := make([]string, 0)
+	for _, column := range columns {
+		if strings.HasPrefix(strings.ToUpper(column), "U_") {
+			userFields = append(userFields, column)
+		}
+	}
+	return userFields, nil
+}
+
+func findColumn(columns []string, name string) (string, bool) {
+	for _, column := range columns {
+		if strings.EqualFold(column, name) {
+			return column, true
+		}
+	}
+	return "", false
+}
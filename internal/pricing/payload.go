@@ -0,0 +1,134 @@
+package pricing
+
+import "time"
+
+// Customer is the OCRD row driving the price list and discount rules.
+type Customer struct {
+	CardCode  string
+	ListNum   int
+	GroupCode int
+	DiscRel   string
+}
+
+// ItemPrice is an OITM item with its ITM1 row for one price list.
+type ItemPrice struct {
+	ItemCode   string
+	FirmCode   *int
+	ItmsGrpCod *int
+	PriceList  int
+	Price      *float64
+	Currency   *string
+}
+
+// SpecialPrice is an OSPP row, unfiltered so the trace can tell why it was skipped.
+type SpecialPrice struct {
+	CardCode  string
+	ItemCode  string
+	ListNum   *int
+	Price     *float64
+	Discount  *float64
+	Valid     string
+	ValidFrom *time.Time
+	ValidTo   *time.Time
+}
+
+// SpecialPeriod is an SPP1 row: a date ranged price of an OSPP special price.
+type SpecialPeriod struct {
+	CardCode string
+	ItemCode string
+	LineNum  int
+	FromDate *time.Time
+	ToDate   *time.Time
+	Price    *float64
+	Discount *float64
+}
+
+// VolumeBreak is an SPP2 row: a quantity break of an SPP1 period.
+type VolumeBreak struct {
+	CardCode   string
+	ItemCode   string
+	PeriodLine int
+	Amount     float64
+	Price      *float64
+	Discount   *float64
+}
+
+// DiscountRule is one EDG1 line joined with its OEDG header.
+type DiscountRule struct {
+	AbsEntry    int
+	Type        string
+	ObjType     string
+	ObjCode     string
+	ValidFor    string
+	ValidFrom   *time.Time
+	ValidTo     *time.Time
+	LineObjType string
+	ObjKey      string
+	Discount    *float64
+}
+
+// Data holds the raw rows for any number of customers and items; Resolve picks
+// the rows that belong to the customer it prices for.
+type Data struct {
+	Items         []ItemPrice
+	SpecialPrices []SpecialPrice
+	Periods       []SpecialPeriod
+	VolumeBreaks  []VolumeBreak
+	Rules         []DiscountRule
+}
+
+type Result struct {
+	ItemCode       string
+	Quantity       float64
+	PriceList      int
+	Currency       *string
+	PriceListPrice *float64
+	OSPPPrice      *float64
+	OSPPDiscount   *float64
+	GroupDiscount  *float64
+	DiscountMode   *string
+	OedgType       *string
+	OedgValidFor   *bool
+	PromoDiscount  *float64
+	Source         string
+	FinalPrice     float64
+	PriceTier      *PriceTier
+	NextBreak      *PriceBreak
+	Explain        *Explanation
+}
+
+// PriceTier is the SPP1 period, and the SPP2 quantity break within it, that
+// replaced the OSPP special price.
+type PriceTier struct {
+	FromDate    *time.Time `json:"fromDate"`
+	ToDate      *time.Time `json:"toDate"`
+	MinQuantity *float64   `json:"minQuantity"`
+	Price       *float64   `json:"price"`
+	Discount    *float64   `json:"discount"`
+}
+
+// PriceBreak is the next SPP2 quantity break above the requested quantity.
+type PriceBreak struct {
+	Quantity float64  `json:"quantity"`
+	Price    *float64 `json:"price"`
+	Discount *float64 `json:"discount"`
+}
+
+// Explanation lists every rule considered for one SKU and the outcome.
+type Explanation struct {
+	SKU          string      `json:"sku"`
+	PriceList    int         `json:"priceList"`
+	DiscountMode string      `json:"discountMode"`
+	Candidates   []Candidate `json:"candidates"`
+	Source       string      `json:"source"`
+	FinalPrice   float64     `json:"finalPrice"`
+}
+
+type Candidate struct {
+	Source   string   `json:"source"`
+	AbsEntry *int     `json:"absEntry,omitempty"`
+	Rule     string   `json:"rule"`
+	Value    *float64 `json:"value"`
+	Applied  bool     `json:"applied"`
+	Reason   string   `json:"reason"`
+}
@@ -0,0 +1,579 @@
+// Package pricing resolves the price a customer pays for an item from the raw
+// SAP pricing rows: OSPP special prices, OEDG/EDG1 discount groups, promotional
+// (type A) discount groups and the customer's ITM1 price list.
+//
+// Precedence, highest first:
+//  1. OSPP explicit price (> 0)
+//  2. OSPP discount
+//  3. discount groups (customer, customer group and global rules), combined
+//     according to the customer's OCRD.DiscRel mode
+//  4. promotional discount group
+//  5. price list price
+package pricing
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SourceSpecialPrice    = "OSPP explicit price"
+	SourceSpecialDiscount = "OSPP discount"
+	SourcePromo           = "Promo (EDG Type A)"
+	SourcePriceList       = "Base price list"
+)
+
+const (
+	candidateSpecialPrice    = "specialPrice"
+	candidateSpecialDiscount = "specialDiscount"
+	candidateDiscountGroup   = "discountGroup"
+	candidatePromo           = "promo"
+	candidatePriceList       = "priceList"
+)
+
+var discountModeNames = map[string]string{
+	"H": "highest",
+	"L": "lowest",
+	"A": "average",
+	"S": "sum",
+	"M": "mixed",
+}
+
+var discountLineNames = map[string]string{
+	"4":  "item",
+	"43": "manufacturer",
+	"52": "item group",
+}
+
+// DiscountMode is the OCRD.DiscRel mode, H when it is not set.
+func DiscountMode(customer Customer) string {
+	if mode := strings.TrimSpace(customer.DiscRel); mode != "" {
+		return mode
+	}
+	return "H"
+}
+
+// DiscountSource is the price source reported when discount groups win.
+func DiscountSource(mode string) string {
+	if name, ok := discountModeNames[mode]; ok {
+		return fmt.Sprintf("Discount groups (%s)", name)
+	}
+	return "Discount groups"
+}
+
+// Options are the per request inputs of Resolve. Items missing from Quantities
+// are priced for a quantity of 1.
+type Options struct {
+	Date       time.Time
+	Quantities map[string]float64
+	Explain    bool
+}
+
+// Resolve prices every item of data that is on the customer's price list, as
+// of opts.Date, ordered by ItemCode. Items without a price list row are skipped.
+func Resolve(customer Customer, data Data, opts Options) []Result {
+	day := truncateDay(opts.Date)
+	mode := DiscountMode(customer)
+	rows := indexCustomerRows(customer, data)
+
+	quantities := make(map[string]float64, len(opts.Quantities))
+	for sku, quantity := range opts.Quantities {
+		quantities[strings.ToUpper(sku)] = quantity
+	}
+
+	results := make([]Result, 0, len(data.Items))
+	for _, item := range data.Items {
+		if item.PriceList != customer.ListNum {
+			continue
+		}
+		key := strings.ToUpper(item.ItemCode)
+		quantity, ok := quantities[key]
+		if !ok {
+			quantity = 1
+		}
+		results = append(results, resolveItem(customer, mode, item, rows.forItem(key), day, quantity, opts.Explain))
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].ItemCode < results[j].ItemCode })
+	return results
+}
+
+// customerRows are the rows of Data that concern one customer, keyed by the
+// upper cased ItemCode so that pricing many customers stays linear.
+type customerRows struct {
+	specialPrices map[string][]SpecialPrice
+	periods       map[string][]SpecialPeriod
+	volumeBreaks  map[string][]VolumeBreak
+	itemRules     map[string][]DiscountRule
+	otherRules    []DiscountRule
+}
+
+func indexCustomerRows(customer Customer, data Data) customerRows {
+	rows := customerRows{
+		specialPrices: map[string][]SpecialPrice{},
+		periods:       map[string][]SpecialPeriod{},
+		volumeBreaks:  map[string][]VolumeBreak{},
+		itemRules:     map[string][]DiscountRule{},
+	}
+	for _, row := range data.SpecialPrices {
+		if strings.EqualFold(row.CardCode, customer.CardCode) {
+			key := strings.ToUpper(row.ItemCode)
+			rows.specialPrices[key] = append(rows.specialPrices[key], row)
+		}
+	}
+	for _, row := range data.Periods {
+		if strings.EqualFold(row.CardCode, customer.CardCode) {
+			key := strings.ToUpper(row.ItemCode)
+			rows.periods[key] = append(rows.periods[key], row)
+		}
+	}
+	for _, row := range data.VolumeBreaks {
+		if strings.EqualFold(row.CardCode, customer.CardCode) {
+			key := strings.ToUpper(row.ItemCode)
+			rows.volumeBreaks[key] = append(rows.volumeBreaks[key], row)
+		}
+	}
+	for _, rule := range data.Rules {
+		if rule.Type != "A" && !appliesToCustomer(rule, customer) {
+			continue
+		}
+		if rule.LineObjType == "4" {
+			key := strings.ToUpper(strings.TrimSpace(rule.ObjKey))
+			rows.itemRules[key] = append(rows.itemRules[key], rule)
+			continue
+		}
+		rows.otherRules = append(rows.otherRules, rule)
+	}
+	return rows
+}
+
+func (rows customerRows) forItem(key string) Data {
+	rules := rows.itemRules[key]
+	if len(rows.otherRules) > 0 {
+		rules = append(append(make([]DiscountRule, 0, len(rules)+len(rows.otherRules)), rules...), rows.otherRules...)
+		sort.SliceStable(rules, func(i, j int) bool { return rules[i].AbsEntry < rules[j].AbsEntry })
+	}
+	return Data{
+		SpecialPrices: rows.specialPrices[key],
+		Periods:       rows.periods[key],
+		VolumeBreaks:  rows.volumeBreaks[key],
+		Rules:         rules,
+	}
+}
+
+func resolveItem(customer Customer, mode string, item ItemPrice, data Data, day time.Time, quantity float64, explain bool) Result {
+	result := Result{
+		ItemCode:       item.ItemCode,
+		Quantity:       quantity,
+		PriceList:      customer.ListNum,
+		Currency:       item.Currency,
+		PriceListPrice: roundPtr(item.Price),
+	}
+
+	var candidates []Candidate
+	note := func(c Candidate) {
+		if explain {
+			candidates = append(candidates, c)
+		}
+	}
+
+	special := specialPriceFor(customer, item, data.SpecialPrices, day, note)
+	if special != nil {
+		var rule string
+		special, rule, result.PriceTier, result.NextBreak = specialTier(*special, data, day, quantity, note)
+		result.OSPPPrice = roundPtr(special.Price)
+		result.OSPPDiscount = roundPtr(special.Discount)
+		note(Candidate{Source: candidateSpecialPrice, Rule: rule, Value: result.OSPPPrice})
+		note(Candidate{Source: candidateSpecialDiscount, Rule: rule, Value: result.OSPPDiscount})
+	}
+
+	lines := discountLinesFor(customer, item, data.Rules, day, note)
+	groupPct := combineDiscounts(mode, lines)
+	if groupPct != nil {
+		result.GroupDiscount = roundPtr(groupPct)
+		result.DiscountMode = &mode
+	}
+
+	promo := promoFor(item, data.Rules, day, note)
+	if promo != nil {
+		result.PromoDiscount = roundPtr(promo.Discount)
+	}
+
+	listPrice := 0.0
+	if item.Price != nil {
+		listPrice = *item.Price
+	}
+	discounted := func(pct float64) float64 {
+		return listPrice * (100.0 - pct) / 100.0
+	}
+
+	switch {
+	case special != nil && special.Price != nil && *special.Price > 0:
+		result.Source = SourceSpecialPrice
+		result.FinalPrice = *special.Price
+	case special != nil && special.Discount != nil:
+		result.Source = SourceSpecialDiscount
+		result.FinalPrice = discounted(*special.Discount)
+	case groupPct != nil:
+		ruleType := discountRuleType(mode, lines)
+		valid := true
+		result.Source = DiscountSource(mode)
+		result.OedgType = &ruleType
+		result.OedgValidFor = &valid
+		result.FinalPrice = discounted(*groupPct)
+	case promo != nil && promo.Discount != nil:
+		ruleType := promo.Type
+		valid := true
+		result.Source = SourcePromo
+		result.OedgType = &ruleType
+		result.OedgValidFor = &valid
+		result.FinalPrice = discounted(*promo.Discount)
+	default:
+		result.Source = SourcePriceList
+		result.FinalPrice = listPrice
+	}
+	result.FinalPrice = Round(result.FinalPrice)
+
+	if explain {
+		note(Candidate{
+			Source: candidatePriceList,
+			Rule:   fmt.Sprintf("price list %d", customer.ListNum),
+			Value:  result.PriceListPrice,
+		})
+		result.Explain = &Explanation{
+			SKU:          item.ItemCode,
+			PriceList:    customer.ListNum,
+			DiscountMode: mode,
+			Candidates:   decide(candidates, result.Source, mode),
+			Source:       result.Source,
+			FinalPrice:   result.FinalPrice,
+		}
+	}
+
+	return result
+}
+
+// specialPriceFor returns the OSPP row of the customer for the item, if it is
+// valid on day and either list independent or for the customer's price list.
+// Only the rows it rejects are noted, the caller notes the effective one.
+func specialPriceFor(customer Customer, item ItemPrice, rows []SpecialPrice, day time.Time, note func(Candidate)) *SpecialPrice {
+	var found *SpecialPrice
+	for i := range rows {
+		row := rows[i]
+		if !strings.EqualFold(row.CardCode, customer.CardCode) || !strings.EqualFold(row.ItemCode, item.ItemCode) {
+			continue
+		}
+
+		rule := fmt.Sprintf("special price %s / %s", row.CardCode, row.ItemCode)
+		reason := ""
+		switch {
+		case row.Valid != "Y":
+			reason = "special price is not active"
+		case !inPeriod(row.ValidFrom, row.ValidTo, day):
+			reason = "special price is outside its validity period"
+		case row.ListNum != nil && *row.ListNum != customer.ListNum:
+			reason = fmt.Sprintf("special price is for price list %d", *row.ListNum)
+		case found != nil:
+			reason = "another special price already applies"
+		}
+
+		if reason != "" {
+			note(Candidate{Source: candidateSpecialPrice, Rule: rule, Value: roundPtr(row.Price), Reason: reason})
+			continue
+		}
+
+		found = &rows[i]
+	}
+	return found
+}
+
+// discountLinesFor returns the EDG1 lines of the customer's, the customer
+// group's and the global discount groups that target the item, its
+// manufacturer or its item group.
+func discountLinesFor(customer Customer, item ItemPrice, rules []DiscountRule, day time.Time, note func(Candidate)) []DiscountRule {
+	lines := make([]DiscountRule, 0)
+	for _, rule := range rules {
+		if !appliesToCustomer(rule, customer) || !targetsItem(rule, item) {
+			continue
+		}
+
+		if reason := ruleInactive(rule, day); reason != "" {
+			note(discountCandidate(candidateDiscountGroup, rule, reason))
+			continue
+		}
+		lines = append(lines, rule)
+		note(discountCandidate(candidateDiscountGroup, rule, ""))
+	}
+	return lines
+}
+
+// promoFor returns the promotional (type A) discount line for the item with
+// the highest discount.
+func promoFor(item ItemPrice, rules []DiscountRule, day time.Time, note func(Candidate)) *DiscountRule {
+	var (
+		best   *DiscountRule
+		active []DiscountRule
+	)
+	for i := range rules {
+		rule := rules[i]
+		if rule.Type != "A" || rule.LineObjType != "4" || !strings.EqualFold(strings.TrimSpace(rule.ObjKey), item.ItemCode) {
+			continue
+		}
+
+		if reason := ruleInactive(rule, day); reason != "" {
+			note(discountCandidate(candidatePromo, rule, reason))
+			continue
+		}
+		active = append(active, rule)
+		if best == nil || discountValue(rule.Discount) > discountValue(best.Discount) {
+			best = &rules[i]
+		}
+	}
+
+	for _, rule := range active {
+		reason := ""
+		if rule.AbsEntry != best.AbsEntry {
+			reason = "a higher promotional discount applies"
+		}
+		note(discountCandidate(candidatePromo, rule, reason))
+	}
+	return best
+}
+
+func appliesToCustomer(rule DiscountRule, customer Customer) bool {
+	code := strings.TrimSpace(rule.ObjCode)
+	switch {
+	case rule.Type == "S" && strings.EqualFold(code, customer.CardCode):
+		return true
+	case rule.Type == "C" && code == strconv.Itoa(customer.GroupCode):
+		return true
+	case strings.TrimSpace(rule.ObjType) == "-1" && code == "0":
+		return true
+	}
+	return false
+}
+
+func targetsItem(rule DiscountRule, item ItemPrice) bool {
+	key := strings.TrimSpace(rule.ObjKey)
+	switch rule.LineObjType {
+	case "4":
+		return strings.EqualFold(key, item.ItemCode)
+	case "43":
+		return matchesInt(key, item.FirmCode)
+	case "52":
+		return matchesInt(key, item.ItmsGrpCod)
+	}
+	return false
+}
+
+func matchesInt(key string, value *int) bool {
+	if value == nil {
+		return false
+	}
+	n, err := strconv.Atoi(key)
+	return err == nil && n == *value
+}
+
+func ruleInactive(rule DiscountRule, day time.Time) string {
+	if rule.ValidFor != "Y" {
+		return "discount group is not active"
+	}
+	if !inPeriod(rule.ValidFrom, rule.ValidTo, day) {
+		return "discount group is outside its validity period"
+	}
+	return ""
+}
+
+func discountCandidate(source string, rule DiscountRule, reason string) Candidate {
+	absEntry := rule.AbsEntry
+	scope := "global"
+	switch rule.Type {
+	case "S":
+		scope = "customer " + strings.TrimSpace(rule.ObjCode)
+	case "C":
+		scope = "customer group " + strings.TrimSpace(rule.ObjCode)
+	case "A":
+		scope = "promotion"
+	}
+	return Candidate{
+		Source:   source,
+		AbsEntry: &absEntry,
+		Rule:     fmt.Sprintf("%s discount group, %s %s", scope, discountLineNames[rule.LineObjType], strings.TrimSpace(rule.ObjKey)),
+		Value:    roundPtr(rule.Discount),
+		Reason:   reason,
+	}
+}
+
+// combineDiscounts aggregates the discount lines of an item. Like SQL
+// aggregates it ignores missing discounts and returns nil when there is
+// nothing to aggregate.
+func combineDiscounts(mode string, lines []DiscountRule) *float64 {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	values := make([]float64, 0, len(lines))
+	for _, line := range lines {
+		if line.Discount != nil {
+			values = append(values, *line.Discount)
+		}
+	}
+
+	var pct float64
+	switch mode {
+	case "L":
+		if len(values) == 0 {
+			return nil
+		}
+		pct = values[0]
+		for _, v := range values[1:] {
+			pct = math.Min(pct, v)
+		}
+	case "A":
+		if len(values) == 0 {
+			return nil
+		}
+		for _, v := range values {
+			pct += v
+		}
+		pct /= float64(len(values))
+	case "S":
+		if len(values) == 0 {
+			return nil
+		}
+		for _, v := range values {
+			pct += v
+		}
+		pct = math.Min(pct, 100.0)
+	case "M":
+		// discounts compound: 10% and 20% leave 72% of the price
+		logSum := 0.0
+		for _, v := range values {
+			if v >= 100 {
+				pct = 100.0
+				return &pct
+			}
+			logSum += math.Log((100.0 - v) / 100.0)
+		}
+		pct = 100.0 * (1.0 - math.Exp(logSum))
+	default:
+		if len(values) == 0 {
+			return nil
+		}
+		pct = values[0]
+		for _, v := range values[1:] {
+			pct = math.Max(pct, v)
+		}
+	}
+	return &pct
+}
+
+// discountRuleType is the OEDG type of the line deciding the discount: the
+// lowest discount in L mode, the highest otherwise.
+func discountRuleType(mode string, lines []DiscountRule) string {
+	ordered := append([]DiscountRule(nil), lines...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i].Discount, ordered[j].Discount
+		if (a == nil) != (b == nil) {
+			return a == nil
+		}
+		if a != nil && *a != *b {
+			if mode == "L" {
+				return *a < *b
+			}
+			return *a > *b
+		}
+		return ordered[i].Type < ordered[j].Type
+	})
+	return ordered[0].Type
+}
+
+// decide marks the candidates of the winning source as applied and explains
+// why every other eligible candidate lost.
+func decide(candidates []Candidate, source, mode string) []Candidate {
+	winner := candidatePriceList
+	switch source {
+	case SourceSpecialPrice:
+		winner = candidateSpecialPrice
+	case SourceSpecialDiscount:
+		winner = candidateSpecialDiscount
+	case SourcePromo:
+		winner = candidatePromo
+	case SourcePriceList:
+	default:
+		winner = candidateDiscountGroup
+	}
+
+	rank := map[string]int{
+		candidateSpecialPrice:    1,
+		candidateSpecialDiscount: 2,
+		candidateDiscountGroup:   3,
+		candidatePromo:           4,
+		candidatePriceList:       5,
+	}
+
+	out := make([]Candidate, len(candidates))
+	for i, c := range candidates {
+		if c.Reason != "" {
+			out[i] = c
+			continue
+		}
+
+		switch {
+		case c.Source == candidateSpecialPrice && (c.Value == nil || *c.Value <= 0):
+			c.Reason = "special price has no explicit price"
+		case c.Source == candidateSpecialDiscount && c.Value == nil:
+			c.Reason = "special price has no discount"
+		case c.Source != winner && rank[c.Source] > rank[winner]:
+			c.Reason = "lower priority than " + source
+		case c.Source != winner:
+			c.Reason = "not applicable"
+		case c.Source == candidateDiscountGroup:
+			c.Applied = true
+			c.Reason = fmt.Sprintf("combined with the other discount groups as %s (%s)", discountModeNames[mode], mode)
+		default:
+			c.Applied = true
+			c.Reason = "applied"
+		}
+		out[i] = c
+	}
+	return out
+}
+
+func inPeriod(from, to *time.Time, day time.Time) bool {
+	if from != nil && truncateDay(*from).After(day) {
+		return false
+	}
+	if to != nil && truncateDay(*to).Before(day) {
+		return false
+	}
+	return true
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func discountValue(pct *float64) float64 {
+	if pct == nil {
+		return math.Inf(-1)
+	}
+	return *pct
+}
+
+// Round matches the DECIMAL(19,4) prices SAP reports.
+func Round(value float64) float64 {
+	return math.Round(value*10000) / 10000
+}
+
+func roundPtr(value *float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	v := Round(*value)
+	return &v
+}
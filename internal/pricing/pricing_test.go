@@ -0,0 +1,504 @@
+package pricing
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func ptrFloat(v float64) *float64 { return &v }
+
+func ptrInt(v int) *int { return &v }
+
+func ptrDate(value string) *time.Time {
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+var testDay = *ptrDate("2024-06-15")
+
+func testCustomer(discRel string) Customer {
+	return Customer{CardCode: "C100", ListNum: 1, GroupCode: 100, DiscRel: discRel}
+}
+
+func testItem(code string, price *float64) ItemPrice {
+	currency := "EUR"
+	return ItemPrice{ItemCode: code, FirmCode: ptrInt(5), ItmsGrpCod: ptrInt(7), PriceList: 1, Price: price, Currency: &currency}
+}
+
+func activeSpecial(price, discount *float64) SpecialPrice {
+	return SpecialPrice{CardCode: "C100", ItemCode: "A1", Price: price, Discount: discount, Valid: "Y"}
+}
+
+// customerRule is an active EDG1 line of the customer's own (S) discount group.
+func customerRule(absEntry int, lineObjType, objKey string, discount *float64) DiscountRule {
+	return DiscountRule{AbsEntry: absEntry, Type: "S", ObjType: "2", ObjCode: "C100", ValidFor: "Y", LineObjType: lineObjType, ObjKey: objKey, Discount: discount}
+}
+
+func groupRule(absEntry int, lineObjType, objKey string, discount *float64) DiscountRule {
+	return DiscountRule{AbsEntry: absEntry, Type: "C", ObjType: "10", ObjCode: "100", ValidFor: "Y", LineObjType: lineObjType, ObjKey: objKey, Discount: discount}
+}
+
+// promoRule is a promotional (type A) line. A promotion with the global
+// ObjType -1 / ObjCode 0 header would also count as a discount group.
+func promoRule(absEntry int, objKey string, discount *float64) DiscountRule {
+	return DiscountRule{AbsEntry: absEntry, Type: "A", ObjType: "2", ObjCode: "", ValidFor: "Y", LineObjType: "4", ObjKey: objKey, Discount: discount}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          Data
+		wantSource    string
+		wantFinal     float64
+		wantGroup     *float64
+		wantOedgType  string
+		wantOSPPPrice *float64
+		wantPromo     *float64
+	}{
+		{
+			name:       "price list only",
+			data:       Data{},
+			wantSource: SourcePriceList,
+			wantFinal:  100,
+		},
+		{
+			name:          "OSPP explicit price wins over everything",
+			data:          Data{SpecialPrices: []SpecialPrice{activeSpecial(ptrFloat(80), ptrFloat(50))}, Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(30)), promoRule(2, "A1", ptrFloat(40))}},
+			wantSource:    SourceSpecialPrice,
+			wantFinal:     80,
+			wantGroup:     ptrFloat(30),
+			wantOedgType:  "",
+			wantOSPPPrice: ptrFloat(80),
+			wantPromo:     ptrFloat(40),
+		},
+		{
+			name:          "OSPP discount applies when the explicit price is zero",
+			data:          Data{SpecialPrices: []SpecialPrice{activeSpecial(ptrFloat(0), ptrFloat(10))}, Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(30))}},
+			wantSource:    SourceSpecialDiscount,
+			wantFinal:     90,
+			wantGroup:     ptrFloat(30),
+			wantOSPPPrice: ptrFloat(0),
+		},
+		{
+			name:          "OSPP zero discount is a discount",
+			data:          Data{SpecialPrices: []SpecialPrice{activeSpecial(nil, ptrFloat(0))}, Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(30))}},
+			wantSource:    SourceSpecialDiscount,
+			wantFinal:     100,
+			wantGroup:     ptrFloat(30),
+			wantOSPPPrice: nil,
+		},
+		{
+			name:         "OSPP without price or discount falls through to discount groups",
+			data:         Data{SpecialPrices: []SpecialPrice{activeSpecial(nil, nil)}, Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(30))}},
+			wantSource:   DiscountSource("H"),
+			wantFinal:    70,
+			wantGroup:    ptrFloat(30),
+			wantOedgType: "S",
+		},
+		{
+			name: "inactive OSPP is ignored",
+			data: Data{SpecialPrices: []SpecialPrice{func() SpecialPrice {
+				row := activeSpecial(ptrFloat(80), nil)
+				row.Valid = "N"
+				return row
+			}()}},
+			wantSource: SourcePriceList,
+			wantFinal:  100,
+		},
+		{
+			name: "OSPP outside its validity period is ignored",
+			data: Data{SpecialPrices: []SpecialPrice{func() SpecialPrice {
+				row := activeSpecial(ptrFloat(80), nil)
+				row.ValidTo = ptrDate("2024-06-14")
+				return row
+			}()}},
+			wantSource: SourcePriceList,
+			wantFinal:  100,
+		},
+		{
+			name: "OSPP of another price list is ignored",
+			data: Data{SpecialPrices: []SpecialPrice{func() SpecialPrice {
+				row := activeSpecial(ptrFloat(80), nil)
+				row.ListNum = ptrInt(2)
+				return row
+			}()}},
+			wantSource: SourcePriceList,
+			wantFinal:  100,
+		},
+		{
+			name:         "discount groups win over promo",
+			data:         Data{Rules: []DiscountRule{groupRule(1, "43", "5", ptrFloat(20)), promoRule(2, "A1", ptrFloat(40))}},
+			wantSource:   DiscountSource("H"),
+			wantFinal:    80,
+			wantGroup:    ptrFloat(20),
+			wantOedgType: "C",
+			wantPromo:    ptrFloat(40),
+		},
+		{
+			name:         "global discount group on the item group",
+			data:         Data{Rules: []DiscountRule{{AbsEntry: 1, Type: "G", ObjType: "-1", ObjCode: "0", ValidFor: "Y", LineObjType: "52", ObjKey: "7", Discount: ptrFloat(5)}}},
+			wantSource:   DiscountSource("H"),
+			wantFinal:    95,
+			wantGroup:    ptrFloat(5),
+			wantOedgType: "G",
+		},
+		{
+			name: "expired and inactive discount groups are ignored",
+			data: Data{Rules: []DiscountRule{
+				func() DiscountRule {
+					rule := customerRule(1, "4", "A1", ptrFloat(20))
+					rule.ValidTo = ptrDate("2024-01-01")
+					return rule
+				}(),
+				func() DiscountRule {
+					rule := groupRule(2, "4", "A1", ptrFloat(30))
+					rule.ValidFor = "N"
+					return rule
+				}(),
+			}},
+			wantSource: SourcePriceList,
+			wantFinal:  100,
+		},
+		{
+			name:         "discount group of another customer is ignored",
+			data:         Data{Rules: []DiscountRule{{AbsEntry: 1, Type: "S", ObjType: "2", ObjCode: "C999", ValidFor: "Y", LineObjType: "4", ObjKey: "A1", Discount: ptrFloat(20)}}},
+			wantSource:   SourcePriceList,
+			wantFinal:    100,
+			wantOedgType: "",
+		},
+		{
+			name:         "null discount group is nothing to aggregate",
+			data:         Data{Rules: []DiscountRule{customerRule(1, "4", "A1", nil)}},
+			wantSource:   SourcePriceList,
+			wantFinal:    100,
+			wantOedgType: "",
+		},
+		{
+			name:         "zero discount group still wins over promo",
+			data:         Data{Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(0)), promoRule(2, "A1", ptrFloat(40))}},
+			wantSource:   DiscountSource("H"),
+			wantFinal:    100,
+			wantGroup:    ptrFloat(0),
+			wantOedgType: "S",
+			wantPromo:    ptrFloat(40),
+		},
+		{
+			name:       "highest promo wins",
+			data:       Data{Rules: []DiscountRule{promoRule(1, "A1", ptrFloat(5)), promoRule(2, "A1", ptrFloat(15))}},
+			wantSource: SourcePromo,
+			wantFinal:  85,
+			wantPromo:  ptrFloat(15),
+		},
+		{
+			name:       "promo without discount falls back to the price list",
+			data:       Data{Rules: []DiscountRule{promoRule(1, "A1", nil)}},
+			wantSource: SourcePriceList,
+			wantFinal:  100,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.data
+			data.Items = []ItemPrice{testItem("A1", ptrFloat(100))}
+			results := Resolve(testCustomer(""), data, Options{Date: testDay})
+			if len(results) != 1 {
+				t.Fatalf("got %d results, want 1", len(results))
+			}
+			got := results[0]
+
+			if got.Source != tt.wantSource {
+				t.Errorf("Source = %q, want %q", got.Source, tt.wantSource)
+			}
+			if got.FinalPrice != tt.wantFinal {
+				t.Errorf("FinalPrice = %v, want %v", got.FinalPrice, tt.wantFinal)
+			}
+			assertFloatPtr(t, "GroupDiscount", got.GroupDiscount, tt.wantGroup)
+			assertFloatPtr(t, "OSPPPrice", got.OSPPPrice, tt.wantOSPPPrice)
+			assertFloatPtr(t, "PromoDiscount", got.PromoDiscount, tt.wantPromo)
+			if tt.wantOedgType == "" && tt.wantSource != SourcePromo {
+				if got.OedgType != nil {
+					t.Errorf("OedgType = %q, want nil", *got.OedgType)
+				}
+			} else if tt.wantOedgType != "" && (got.OedgType == nil || *got.OedgType != tt.wantOedgType) {
+				t.Errorf("OedgType = %v, want %q", got.OedgType, tt.wantOedgType)
+			}
+			if got.Explain != nil {
+				t.Errorf("Explain is set without Options.Explain")
+			}
+		})
+	}
+}
+
+func TestResolveDiscountModes(t *testing.T) {
+	// a 10% customer rule on the item and a 20% customer group rule on the manufacturer
+	rules := []DiscountRule{customerRule(1, "4", "A1", ptrFloat(10)), groupRule(2, "43", "5", ptrFloat(20))}
+
+	tests := []struct {
+		mode      string
+		wantGroup float64
+		wantFinal float64
+		wantType  string
+	}{
+		{mode: "", wantGroup: 20, wantFinal: 80, wantType: "C"},
+		{mode: "H", wantGroup: 20, wantFinal: 80, wantType: "C"},
+		{mode: "L", wantGroup: 10, wantFinal: 90, wantType: "S"},
+		{mode: "A", wantGroup: 15, wantFinal: 85, wantType: "C"},
+		{mode: "S", wantGroup: 30, wantFinal: 70, wantType: "C"},
+		{mode: "M", wantGroup: 28, wantFinal: 72, wantType: "C"},
+	}
+
+	for _, tt := range tests {
+		t.Run("mode "+tt.mode, func(t *testing.T) {
+			data := Data{Items: []ItemPrice{testItem("A1", ptrFloat(100))}, Rules: rules}
+			got := Resolve(testCustomer(tt.mode), data, Options{Date: testDay})[0]
+
+			mode := DiscountMode(testCustomer(tt.mode))
+			if got.Source != DiscountSource(mode) {
+				t.Errorf("Source = %q, want %q", got.Source, DiscountSource(mode))
+			}
+			assertFloatPtr(t, "GroupDiscount", got.GroupDiscount, &tt.wantGroup)
+			if got.FinalPrice != tt.wantFinal {
+				t.Errorf("FinalPrice = %v, want %v", got.FinalPrice, tt.wantFinal)
+			}
+			if got.OedgType == nil || *got.OedgType != tt.wantType {
+				t.Errorf("OedgType = %v, want %q", got.OedgType, tt.wantType)
+			}
+			if got.DiscountMode == nil || *got.DiscountMode != mode {
+				t.Errorf("DiscountMode = %v, want %q", got.DiscountMode, mode)
+			}
+		})
+	}
+}
+
+func TestCombineDiscounts(t *testing.T) {
+	line := func(discount *float64) DiscountRule { return DiscountRule{Discount: discount} }
+
+	tests := []struct {
+		name  string
+		mode  string
+		lines []DiscountRule
+		want  *float64
+	}{
+		{name: "no lines", mode: "H", lines: nil, want: nil},
+		{name: "highest ignores nulls", mode: "H", lines: []DiscountRule{line(nil), line(ptrFloat(5))}, want: ptrFloat(5)},
+		{name: "highest of nulls only", mode: "H", lines: []DiscountRule{line(nil)}, want: nil},
+		{name: "lowest keeps a zero", mode: "L", lines: []DiscountRule{line(ptrFloat(0)), line(ptrFloat(5))}, want: ptrFloat(0)},
+		{name: "lowest of nulls only", mode: "L", lines: []DiscountRule{line(nil)}, want: nil},
+		{name: "average ignores nulls", mode: "A", lines: []DiscountRule{line(nil), line(ptrFloat(10)), line(ptrFloat(20))}, want: ptrFloat(15)},
+		{name: "average of nulls only", mode: "A", lines: []DiscountRule{line(nil)}, want: nil},
+		{name: "sum is capped at 100", mode: "S", lines: []DiscountRule{line(ptrFloat(60)), line(ptrFloat(70))}, want: ptrFloat(100)},
+		{name: "sum of nulls only", mode: "S", lines: []DiscountRule{line(nil)}, want: nil},
+		{name: "mixed stops at 100", mode: "M", lines: []DiscountRule{line(ptrFloat(10)), line(ptrFloat(100))}, want: ptrFloat(100)},
+		// the original query summed LOG terms with nulls counted as 0.0, so
+		// mixed mode reports a zero discount where the other modes report none
+		{name: "mixed of nulls only", mode: "M", lines: []DiscountRule{line(nil)}, want: ptrFloat(0)},
+		{name: "unknown mode is highest", mode: "X", lines: []DiscountRule{line(ptrFloat(5)), line(ptrFloat(8))}, want: ptrFloat(8)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := combineDiscounts(tt.mode, tt.lines)
+			if got != nil {
+				got = roundPtr(got)
+			}
+			assertFloatPtr(t, "discount", got, tt.want)
+		})
+	}
+}
+
+func TestResolveTiers(t *testing.T) {
+	special := activeSpecial(ptrFloat(95), nil)
+	periods := []SpecialPeriod{
+		{CardCode: "C100", ItemCode: "A1", LineNum: 0, FromDate: ptrDate("2024-01-01"), ToDate: ptrDate("2024-03-31"), Price: ptrFloat(70)},
+		{CardCode: "C100", ItemCode: "A1", LineNum: 1, FromDate: ptrDate("2024-06-01"), ToDate: ptrDate("2024-06-30"), Price: ptrFloat(90)},
+	}
+	breaks := []VolumeBreak{
+		{CardCode: "C100", ItemCode: "A1", PeriodLine: 1, Amount: 50, Price: ptrFloat(80)},
+		{CardCode: "C100", ItemCode: "A1", PeriodLine: 1, Amount: 10, Price: ptrFloat(85)},
+		{CardCode: "C100", ItemCode: "A1", PeriodLine: 0, Amount: 5, Price: ptrFloat(60)},
+	}
+
+	tests := []struct {
+		name         string
+		day          time.Time
+		quantity     *float64
+		wantFinal    float64
+		wantTier     bool
+		wantMinQty   *float64
+		wantNext     *float64
+		wantNextCost *float64
+	}{
+		{name: "below the first break", day: testDay, quantity: ptrFloat(5), wantFinal: 90, wantTier: true, wantNext: ptrFloat(10), wantNextCost: ptrFloat(85)},
+		{name: "default quantity is 1", day: testDay, wantFinal: 90, wantTier: true, wantNext: ptrFloat(10), wantNextCost: ptrFloat(85)},
+		{name: "on a break", day: testDay, quantity: ptrFloat(10), wantFinal: 85, wantTier: true, wantMinQty: ptrFloat(10), wantNext: ptrFloat(50), wantNextCost: ptrFloat(80)},
+		{name: "between breaks", day: testDay, quantity: ptrFloat(20), wantFinal: 85, wantTier: true, wantMinQty: ptrFloat(10), wantNext: ptrFloat(50), wantNextCost: ptrFloat(80)},
+		{name: "above the last break", day: testDay, quantity: ptrFloat(60), wantFinal: 80, wantTier: true, wantMinQty: ptrFloat(50)},
+		{name: "breaks of another period do not apply", day: *ptrDate("2024-02-01"), quantity: ptrFloat(10), wantFinal: 60, wantTier: true, wantMinQty: ptrFloat(5)},
+		{name: "no period covers the date", day: *ptrDate("2024-05-01"), quantity: ptrFloat(60), wantFinal: 95},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := Data{
+				Items:         []ItemPrice{testItem("A1", ptrFloat(100))},
+				SpecialPrices: []SpecialPrice{special},
+				Periods:       periods,
+				VolumeBreaks:  breaks,
+			}
+			opts := Options{Date: tt.day}
+			if tt.quantity != nil {
+				opts.Quantities = map[string]float64{"a1": *tt.quantity}
+			}
+			got := Resolve(testCustomer(""), data, opts)[0]
+
+			if got.Source != SourceSpecialPrice {
+				t.Errorf("Source = %q, want %q", got.Source, SourceSpecialPrice)
+			}
+			if got.FinalPrice != tt.wantFinal {
+				t.Errorf("FinalPrice = %v, want %v", got.FinalPrice, tt.wantFinal)
+			}
+			if (got.PriceTier != nil) != tt.wantTier {
+				t.Fatalf("PriceTier = %+v, want tier %t", got.PriceTier, tt.wantTier)
+			}
+			if got.PriceTier != nil {
+				assertFloatPtr(t, "PriceTier.MinQuantity", got.PriceTier.MinQuantity, tt.wantMinQty)
+				assertFloatPtr(t, "PriceTier.Price", got.PriceTier.Price, &tt.wantFinal)
+			}
+			if tt.wantNext == nil {
+				if got.NextBreak != nil {
+					t.Errorf("NextBreak = %+v, want nil", got.NextBreak)
+				}
+				return
+			}
+			if got.NextBreak == nil {
+				t.Fatalf("NextBreak = nil, want %v", *tt.wantNext)
+			}
+			if got.NextBreak.Quantity != *tt.wantNext {
+				t.Errorf("NextBreak.Quantity = %v, want %v", got.NextBreak.Quantity, *tt.wantNext)
+			}
+			assertFloatPtr(t, "NextBreak.Price", got.NextBreak.Price, tt.wantNextCost)
+		})
+	}
+}
+
+func TestResolveExplain(t *testing.T) {
+	data := Data{
+		Items: []ItemPrice{testItem("A1", ptrFloat(100))},
+		SpecialPrices: []SpecialPrice{
+			activeSpecial(ptrFloat(80), nil),
+			func() SpecialPrice {
+				row := activeSpecial(ptrFloat(70), nil)
+				row.Valid = "N"
+				return row
+			}(),
+		},
+		Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(10)), promoRule(2, "A1", ptrFloat(5)), promoRule(3, "A1", ptrFloat(15))},
+	}
+	got := Resolve(testCustomer("L"), data, Options{Date: testDay, Explain: true})[0]
+	if got.Explain == nil {
+		t.Fatal("Explain = nil with Options.Explain")
+	}
+	if got.Explain.Source != SourceSpecialPrice || got.Explain.FinalPrice != 80 || got.Explain.DiscountMode != "L" || got.Explain.PriceList != 1 {
+		t.Errorf("Explain = %+v", got.Explain)
+	}
+
+	want := []struct {
+		source  string
+		applied bool
+		reason  string
+	}{
+		{candidateSpecialPrice, false, "special price is not active"},
+		{candidateSpecialPrice, true, "applied"},
+		{candidateSpecialDiscount, false, "special price has no discount"},
+		{candidateDiscountGroup, false, "lower priority than " + SourceSpecialPrice},
+		{candidatePromo, false, "a higher promotional discount applies"},
+		{candidatePromo, false, "lower priority than " + SourceSpecialPrice},
+		{candidatePriceList, false, "lower priority than " + SourceSpecialPrice},
+	}
+	candidates := got.Explain.Candidates
+	if len(candidates) != len(want) {
+		t.Fatalf("got %d candidates, want %d: %+v", len(candidates), len(want), candidates)
+	}
+	applied := 0
+	for i, w := range want {
+		c := candidates[i]
+		if c.Source != w.source || c.Applied != w.applied || c.Reason != w.reason {
+			t.Errorf("candidate %d = {%s %t %q}, want {%s %t %q}", i, c.Source, c.Applied, c.Reason, w.source, w.applied, w.reason)
+		}
+		if c.Applied {
+			applied++
+		}
+	}
+	if applied != 1 {
+		t.Errorf("%d candidates applied, want 1", applied)
+	}
+}
+
+func TestResolveExplainDiscountGroups(t *testing.T) {
+	data := Data{
+		Items: []ItemPrice{testItem("A1", ptrFloat(100))},
+		Rules: []DiscountRule{customerRule(1, "4", "A1", ptrFloat(10)), groupRule(2, "52", "7", ptrFloat(20))},
+	}
+	got := Resolve(testCustomer("S"), data, Options{Date: testDay, Explain: true})[0]
+
+	for _, c := range got.Explain.Candidates {
+		switch c.Source {
+		case candidateDiscountGroup:
+			if !c.Applied || !strings.Contains(c.Reason, "sum (S)") {
+				t.Errorf("discount group candidate = %+v, want applied in sum mode", c)
+			}
+			if c.AbsEntry == nil {
+				t.Errorf("discount group candidate has no AbsEntry")
+			}
+		case candidatePriceList:
+			if c.Applied || c.Reason != "lower priority than "+DiscountSource("S") {
+				t.Errorf("price list candidate = %+v", c)
+			}
+		}
+	}
+}
+
+func TestResolveItems(t *testing.T) {
+	data := Data{Items: []ItemPrice{
+		testItem("B2", ptrFloat(10)),
+		testItem("A1", ptrFloat(20)),
+		func() ItemPrice {
+			item := testItem("C3", ptrFloat(30))
+			item.PriceList = 2
+			return item
+		}(),
+		testItem("D4", nil),
+	}}
+	got := Resolve(testCustomer(""), data, Options{Date: testDay, Quantities: map[string]float64{"b2": 3}})
+
+	if len(got) != 3 {
+		t.Fatalf("got %d results, want 3 (items of other price lists are skipped)", len(got))
+	}
+	if got[0].ItemCode != "A1" || got[1].ItemCode != "B2" || got[2].ItemCode != "D4" {
+		t.Errorf("results are not ordered by ItemCode: %s, %s, %s", got[0].ItemCode, got[1].ItemCode, got[2].ItemCode)
+	}
+	if got[0].Quantity != 1 || got[1].Quantity != 3 {
+		t.Errorf("Quantity = %v, %v, want 1, 3", got[0].Quantity, got[1].Quantity)
+	}
+	if got[2].PriceListPrice != nil || got[2].FinalPrice != 0 || got[2].Source != SourcePriceList {
+		t.Errorf("item without a price = %+v", got[2])
+	}
+}
+
+func assertFloatPtr(t *testing.T, name string, got, want *float64) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil:
+		t.Errorf("%s = nil, want %v", name, *want)
+	case want == nil:
+		t.Errorf("%s = %v, want nil", name, *got)
+	case *got != *want:
+		t.Errorf("%s = %v, want %v", name, *got, *want)
+	}
+}
@@ -0,0 +1,119 @@
+package pricing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	candidateSpecialPeriod = "specialPeriod"
+	candidateVolumeBreak   = "volumeBreak"
+)
+
+// specialTier applies the SPP1 period covering day and, within it, the highest
+// SPP2 quantity break not above quantity to the OSPP special price. It returns
+// the effective special price, its label for the trace, the tier applied and
+// the next quantity break, if any.
+func specialTier(special SpecialPrice, data Data, day time.Time, quantity float64, note func(Candidate)) (*SpecialPrice, string, *PriceTier, *PriceBreak) {
+	rule := fmt.Sprintf("special price %s / %s", special.CardCode, special.ItemCode)
+
+	var period *SpecialPeriod
+	for i := range data.Periods {
+		row := data.Periods[i]
+		if !sameSpecialPrice(special, row.CardCode, row.ItemCode) {
+			continue
+		}
+
+		label := fmt.Sprintf("special price period %s", periodLabel(row.FromDate, row.ToDate))
+		switch {
+		case !inPeriod(row.FromDate, row.ToDate, day):
+			note(Candidate{Source: candidateSpecialPeriod, Rule: label, Value: roundPtr(row.Price), Reason: "period does not cover the date"})
+		case period != nil:
+			note(Candidate{Source: candidateSpecialPeriod, Rule: label, Value: roundPtr(row.Price), Reason: "an earlier period already covers the date"})
+		default:
+			period = &data.Periods[i]
+			note(Candidate{Source: candidateSpecialPeriod, Rule: label, Value: roundPtr(row.Price), Applied: true, Reason: "period covers the date"})
+		}
+	}
+	if period == nil {
+		return &special, rule, nil, nil
+	}
+
+	effective := special
+	effective.Price = period.Price
+	effective.Discount = period.Discount
+	rule = fmt.Sprintf("special price period %s", periodLabel(period.FromDate, period.ToDate))
+	tier := &PriceTier{
+		FromDate: period.FromDate,
+		ToDate:   period.ToDate,
+		Price:    roundPtr(period.Price),
+		Discount: roundPtr(period.Discount),
+	}
+
+	breaks := make([]VolumeBreak, 0)
+	for _, row := range data.VolumeBreaks {
+		if sameSpecialPrice(special, row.CardCode, row.ItemCode) && row.PeriodLine == period.LineNum {
+			breaks = append(breaks, row)
+		}
+	}
+	sort.SliceStable(breaks, func(i, j int) bool { return breaks[i].Amount < breaks[j].Amount })
+
+	applied := -1
+	var next *PriceBreak
+	for i, row := range breaks {
+		if row.Amount <= quantity {
+			applied = i
+			continue
+		}
+		if next == nil {
+			next = &PriceBreak{Quantity: row.Amount, Price: roundPtr(row.Price), Discount: roundPtr(row.Discount)}
+		}
+	}
+
+	for i, row := range breaks {
+		candidate := Candidate{
+			Source: candidateVolumeBreak,
+			Rule:   fmt.Sprintf("quantity break from %g", row.Amount),
+			Value:  roundPtr(row.Price),
+		}
+		switch {
+		case i == applied:
+			candidate.Applied = true
+			candidate.Reason = fmt.Sprintf("highest break reached by quantity %g", quantity)
+		case i < applied:
+			candidate.Reason = "a higher break applies"
+		default:
+			candidate.Reason = fmt.Sprintf("quantity %g is below the break", quantity)
+		}
+		note(candidate)
+	}
+
+	if applied >= 0 {
+		row := breaks[applied]
+		amount := row.Amount
+		effective.Price = row.Price
+		effective.Discount = row.Discount
+		rule = fmt.Sprintf("%s, quantity break from %g", rule, amount)
+		tier.MinQuantity = &amount
+		tier.Price = roundPtr(row.Price)
+		tier.Discount = roundPtr(row.Discount)
+	}
+
+	return &effective, rule, tier, next
+}
+
+func sameSpecialPrice(special SpecialPrice, cardCode, itemCode string) bool {
+	return strings.EqualFold(special.CardCode, cardCode) && strings.EqualFold(special.ItemCode, itemCode)
+}
+
+func periodLabel(from, to *time.Time) string {
+	format := func(t *time.Time) string {
+		if t == nil {
+			return "open"
+		}
+		return t.Format("2006-01-02")
+	}
+	return format(from) + ".." + format(to)
+}
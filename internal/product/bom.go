@@ -0,0 +1,395 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"sql-service/internal/pricing"
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/db"
+)
+
+const (
+	bomModeNested = "nested"
+	bomModeFlat   = "flat"
+
+	maxBomDepth = 10
+)
+
+type bomNode struct {
+	Code     string
+	Name     *string
+	TreeType string
+	Quantity float64
+	Lines    []bomLine
+}
+
+type bomLine struct {
+	Code      string
+	ItemName  *string
+	Quantity  float64
+	Warehouse *string
+	IssueMthd *string
+}
+
+type itemListPrice struct {
+	Price    *float64
+	Currency *string
+}
+
+// GetBomNodes loads the OITT headers of codes with their ITT1 lines, keyed by
+// the upper cased code. Codes without a tree are missing from the result.
+func (r *ProductRepository) GetBomNodes(ctx context.Context, codes []string) (map[string]*bomNode, error) {
+	nodes := make(map[string]*bomNode, len(codes))
+	if len(codes) == 0 {
+		return nodes, nil
+	}
+
+	list, args := namedList("code", codes)
+	headerRows, err := r.Db.QueryContext(ctx, fmt.Sprintf(`
+SELECT Code, Name, TreeType, Qauntity
+FROM OITT WITH (NOLOCK)
+WHERE Code IN (%s)`, list), args...)
+	if err != nil {
+		return nil, apperr.Upstream("product tree query failed", err)
+	}
+	defer headerRows.Close()
+
+	for headerRows.Next() {
+		var (
+			node     bomNode
+			name     sql.NullString
+			quantity sql.NullFloat64
+		)
+		if err := headerRows.Scan(&node.Code, &name, &node.TreeType, &quantity); err != nil {
+			return nil, apperr.Upstream("product tree query failed", err)
+		}
+		node.Name = db.StringPtr(name)
+		node.Quantity = quantity.Float64
+		nodes[strings.ToUpper(node.Code)] = &node
+	}
+	if err := headerRows.Err(); err != nil {
+		return nil, apperr.Upstream("product tree query failed", err)
+	}
+	if len(nodes) == 0 {
+		return nodes, nil
+	}
+
+	lineRows, err := r.Db.QueryContext(ctx, fmt.Sprintf(`
+SELECT Father, Code, ItemName, Quantity, Warehouse, IssueMthd
+FROM ITT1 WITH (NOLOCK)
+WHERE Father IN (%s)
+ORDER BY Father, VisOrder, ChildNum, Code`, list), args...)
+	if err != nil {
+		return nil, apperr.Upstream("product tree query failed", err)
+	}
+	defer lineRows.Close()
+
+	for lineRows.Next() {
+		var (
+			father    string
+			line      bomLine
+			itemName  sql.NullString
+			quantity  sql.NullFloat64
+			warehouse sql.NullString
+			issueMthd sql.NullString
+		)
+		if err := lineRows.Scan(&father, &line.Code, &itemName, &quantity, &warehouse, &issueMthd); err != nil {
+			return nil, apperr.Upstream("product tree query failed", err)
+		}
+		line.ItemName = db.StringPtr(itemName)
+		line.Quantity = quantity.Float64
+		line.Warehouse = db.StringPtr(warehouse)
+		line.IssueMthd = db.StringPtr(issueMthd)
+		if node := nodes[strings.ToUpper(father)]; node != nil {
+			node.Lines = append(node.Lines, line)
+		}
+	}
+	if err := lineRows.Err(); err != nil {
+		return nil, apperr.Upstream("product tree query failed", err)
+	}
+	return nodes, nil
+}
+
+// GetItemListPrices returns the ITM1 price of codes in priceList, keyed by the
+// upper cased code.
+func (r *ProductRepository) GetItemListPrices(ctx context.Context, codes []string, priceList int) (map[string]itemListPrice, error) {
+	prices := make(map[string]itemListPrice, len(codes))
+	if len(codes) == 0 {
+		return prices, nil
+	}
+
+	list, args := namedList("code", codes)
+	args = append(args, sql.Named("priceList", priceList))
+	rows, err := r.Db.QueryContext(ctx, fmt.Sprintf(`
+SELECT ItemCode, Price, Currency
+FROM ITM1 WITH (NOLOCK)
+WHERE ItemCode IN (%s)
+  AND PriceList = @priceList`, list), args...)
+	if err != nil {
+		return nil, apperr.Upstream("price list query failed", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			code     string
+			price    sql.NullFloat64
+			currency sql.NullString
+		)
+		if err := rows.Scan(&code, &price, &currency); err != nil {
+			return nil, apperr.Upstream("price list query failed", err)
+		}
+		prices[strings.ToUpper(code)] = itemListPrice{Price: db.FloatPtr(price), Currency: db.StringPtr(currency)}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("price list query failed", err)
+	}
+	return prices, nil
+}
+
+// ExplodeBoms explodes the trees of the skus level by level down to
+// dto.Depth, multiplying quantities down the tree, and rolls up component
+// costs from dto.CostPriceList when set. A component already on its own path
+// is reported as a cycle and not expanded again.
+func (service *ProductService) ExplodeBoms(ctx context.Context, dto *ProductSkusDto) ([]BomExplosion, error) {
+	start := time.Now()
+	log.Printf("ExplodeBoms: start, skus=%d, depth=%d, mode=%s", len(dto.Skus), dto.Depth, dto.Mode)
+
+	skus := dedupeSkus(dto.Skus)
+	if len(skus) == 0 {
+		return nil, apperr.Validation("sku list cannot be empty", "skus must contain at least one sku")
+	}
+	depth := max(dto.Depth, 1)
+	if depth > maxBomDepth {
+		return nil, apperr.Validation("depth is too large", fmt.Sprintf("depth must be between 1 and %d", maxBomDepth))
+	}
+	mode := dto.Mode
+	if mode == "" {
+		mode = bomModeNested
+	}
+	if mode != bomModeNested && mode != bomModeFlat {
+		return nil, apperr.Validation("invalid mode", "mode must be nested or flat")
+	}
+
+	resolution, err := service.resolveSkus(ctx, skus, dto.CardCode)
+	if err != nil {
+		log.Printf("ExplodeBoms: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+	skus = resolution.ItemCodes
+
+	nodes := map[string]*bomNode{}
+	queried := map[string]bool{}
+	frontier := skus
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		for _, code := range frontier {
+			queried[strings.ToUpper(code)] = true
+		}
+		loaded, err := runSkuChunks(ctx, frontier, func(ctx context.Context, chunk []string) ([]*bomNode, error) {
+			found, err := service.productRepository.GetBomNodes(ctx, chunk)
+			if err != nil {
+				return nil, err
+			}
+			out := make([]*bomNode, 0, len(found))
+			for _, node := range found {
+				out = append(out, node)
+			}
+			return out, nil
+		})
+		if err != nil {
+			log.Printf("ExplodeBoms: error after %s: %v", time.Since(start), err)
+			return nil, err
+		}
+
+		next := make([]string, 0)
+		for _, node := range loaded {
+			nodes[strings.ToUpper(node.Code)] = node
+		}
+		for _, node := range loaded {
+			for _, line := range node.Lines {
+				key := strings.ToUpper(line.Code)
+				if !queried[key] {
+					queried[key] = true
+					next = append(next, line.Code)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	var prices map[string]itemListPrice
+	if dto.CostPriceList != nil {
+		codes := make([]string, 0)
+		seen := map[string]bool{}
+		for _, node := range nodes {
+			for _, line := range node.Lines {
+				if key := strings.ToUpper(line.Code); !seen[key] {
+					seen[key] = true
+					codes = append(codes, line.Code)
+				}
+			}
+		}
+		loaded, err := runSkuChunks(ctx, codes, func(ctx context.Context, chunk []string) ([]map[string]itemListPrice, error) {
+			found, err := service.productRepository.GetItemListPrices(ctx, chunk, *dto.CostPriceList)
+			if err != nil {
+				return nil, err
+			}
+			return []map[string]itemListPrice{found}, nil
+		})
+		if err != nil {
+			log.Printf("ExplodeBoms: error after %s: %v", time.Since(start), err)
+			return nil, err
+		}
+		prices = map[string]itemListPrice{}
+		for _, found := range loaded {
+			for key, price := range found {
+				prices[key] = price
+			}
+		}
+	}
+
+	result := make([]BomExplosion, 0, len(skus))
+	for _, sku := range skus {
+		node := nodes[strings.ToUpper(sku)]
+		if node == nil {
+			continue
+		}
+
+		explosion := BomExplosion{
+			Code:     node.Code,
+			Name:     node.Name,
+			TreeType: node.TreeType,
+			Quantity: node.Quantity,
+			Depth:    depth,
+		}
+		explosion.Components = explodeBomNode(nodes, node, 1, 1, depth, []string{node.Code})
+		if prices != nil {
+			explosion.Cost, explosion.Currency, explosion.Warnings = rollupBomCost(explosion.Components, prices)
+		}
+		if mode == bomModeFlat {
+			explosion.Components = flattenBomComponents(explosion.Components)
+		}
+		result = append(result, explosion)
+	}
+
+	result = echoInputs(result, resolution, func(e *BomExplosion) string { return e.Code }, func(e *BomExplosion, input string) { e.Input = input })
+
+	log.Printf("ExplodeBoms: success, trees=%d, nodes=%d, elapsed=%s", len(result), len(nodes), time.Since(start))
+	return result, nil
+}
+
+// explodeBomNode expands the lines of node for factor units of it. A tree is
+// defined for node.Quantity units, so every line is scaled by factor over it.
+func explodeBomNode(nodes map[string]*bomNode, node *bomNode, factor float64, level, depth int, path []string) []BomComponent {
+	perUnit := factor
+	if node.Quantity > 0 {
+		perUnit = factor / node.Quantity
+	}
+
+	components := make([]BomComponent, 0, len(node.Lines))
+	for _, line := range node.Lines {
+		componentPath := append(slices.Clone(path), line.Code)
+		component := BomComponent{
+			Code:          line.Code,
+			ItemName:      line.ItemName,
+			Level:         level,
+			Path:          componentPath,
+			Quantity:      line.Quantity,
+			TotalQuantity: line.Quantity * perUnit,
+			Warehouse:     line.Warehouse,
+			IssueMthd:     line.IssueMthd,
+		}
+
+		if child := nodes[strings.ToUpper(line.Code)]; child != nil {
+			treeType := child.TreeType
+			component.TreeType = &treeType
+			switch {
+			case slices.ContainsFunc(path, func(code string) bool { return strings.EqualFold(code, line.Code) }):
+				component.Cycle = true
+			case level < depth:
+				component.Components = explodeBomNode(nodes, child, component.TotalQuantity, level+1, depth, componentPath)
+			}
+		}
+		components = append(components, component)
+	}
+	return components
+}
+
+// rollupBomCost prices the leaves from prices and sums them up the tree. A
+// missing price leaves the cost of its ancestors unknown and is reported in
+// the warnings, as are mixed currencies.
+func rollupBomCost(components []BomComponent, prices map[string]itemListPrice) (*float64, *string, []string) {
+	var (
+		warnings   []string
+		currencies = map[string]bool{}
+		currency   *string
+	)
+
+	var rollup func(components []BomComponent) *float64
+	rollup = func(components []BomComponent) *float64 {
+		total := 0.0
+		known := true
+		for i := range components {
+			component := &components[i]
+			price := prices[strings.ToUpper(component.Code)]
+			component.UnitPrice = price.Price
+			component.Currency = price.Currency
+
+			var cost *float64
+			if len(component.Components) > 0 {
+				cost = rollup(component.Components)
+			} else if price.Price != nil {
+				value := pricing.Round(*price.Price * component.TotalQuantity)
+				cost = &value
+				if price.Currency != nil && !currencies[*price.Currency] {
+					currencies[*price.Currency] = true
+					currency = price.Currency
+				}
+			} else {
+				warnings = append(warnings, "no price for "+strings.Join(component.Path, " > "))
+			}
+
+			component.Cost = cost
+			if cost == nil {
+				known = false
+				continue
+			}
+			total += *cost
+		}
+		if !known {
+			return nil
+		}
+		total = pricing.Round(total)
+		return &total
+	}
+
+	cost := rollup(components)
+	if len(currencies) > 1 {
+		list := make([]string, 0, len(currencies))
+		for code := range currencies {
+			list = append(list, code)
+		}
+		slices.Sort(list)
+		warnings = append(warnings, "components are priced in mixed currencies: "+strings.Join(list, ", "))
+		currency = nil
+	}
+	return cost, currency, warnings
+}
+
+// flattenBomComponents lists the components in preorder without nesting.
+func flattenBomComponents(components []BomComponent) []BomComponent {
+	flat := make([]BomComponent, 0, len(components))
+	for _, component := range components {
+		children := component.Components
+		component.Components = nil
+		flat = append(flat, component)
+		flat = append(flat, flattenBomComponents(children)...)
+	}
+	return flat
+}
@@ -0,0 +1,128 @@
+package product
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// skuChunkSize keeps every query well below SQL Server's 2100 parameter cap.
+	skuChunkSize        = 1000
+	skuChunkConcurrency = 4
+)
+
+// dedupeSkus drops empty and repeated SKUs, keeping the first occurrence order.
+// SKUs differing only in case are the same item.
+func dedupeSkus(skus []string) []string {
+	seen := make(map[string]struct{}, len(skus))
+	out := make([]string, 0, len(skus))
+	for _, sku := range skus {
+		key := strings.ToUpper(strings.TrimSpace(sku))
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, sku)
+	}
+	return out
+}
+
+// inSkuOrder orders rows by the position of their sku in skus, so that the
+// result does not depend on how the skus were chunked. Rows of one sku keep
+// their order, rows of skus that are not listed go last.
+func inSkuOrder[T any](rows []T, skus []string, sku func(*T) string) []T {
+	position := make(map[string]int, len(skus))
+	for i, code := range skus {
+		key := strings.ToUpper(strings.TrimSpace(code))
+		if _, ok := position[key]; !ok {
+			position[key] = i
+		}
+	}
+	rank := func(row *T) int {
+		if i, ok := position[strings.ToUpper(strings.TrimSpace(sku(row)))]; ok {
+			return i
+		}
+		return len(skus)
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rank(&rows[i]) < rank(&rows[j]) })
+	return rows
+}
+
+func chunkSkus(skus []string, size int) [][]string {
+	chunks := make([][]string, 0, (len(skus)+size-1)/size)
+	for start := 0; start < len(skus); start += size {
+		end := min(start+size, len(skus))
+		chunks = append(chunks, skus[start:end])
+	}
+	return chunks
+}
+
+// runSkuChunks runs fn for every chunk of skus, at most skuChunkConcurrency at a
+// time, and concatenates the results in chunk order. The first error cancels
+// the chunks still running and is returned; when ctx ends first its error is
+// returned instead of a partial result.
+func runSkuChunks[T any](ctx context.Context, skus []string, fn func(ctx context.Context, chunk []string) ([]T, error)) ([]T, error) {
+	chunks := chunkSkus(skus, skuChunkSize)
+	if len(chunks) <= 1 {
+		return fn(ctx, skus)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sem := make(chan struct{}, skuChunkConcurrency)
+	results := make([][]T, len(chunks))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk []string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if cctx.Err() != nil {
+				return
+			}
+
+			out, err := fn(cctx, chunk)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = out
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// chunks skipped because the caller gave up left no error of their own
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for _, out := range results {
+		total += len(out)
+	}
+	merged := make([]T, 0, total)
+	for _, out := range results {
+		merged = append(merged, out...)
+	}
+	return merged, nil
+}
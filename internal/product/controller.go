@@ -28,7 +28,9 @@ func NewProductController(router *http.ServeMux, deps ProductControllerDeps) *Pr
 	}
 
 	router.Handle("POST /products", controller.GetProducts())
+	router.Handle("POST /products/priceMatrix", controller.GetPriceMatrix())
 	router.Handle("POST /productTree", controller.GetProductTree())
+	router.Handle("POST /productTree/whereUsed", controller.GetWhereUsed())
 	router.Handle("POST /productStock", controller.GetProductStcok())
 	return controller
 }
@@ -51,7 +53,12 @@ func (Controller *ProductController) GetProducts() http.HandlerFunc {
 			log.Printf("[/products] failed to marshal body for logging: %v", err)
 		}
 
-		data := Controller.ProductService.ProductServiceHandler(body)
+		data, err := Controller.ProductService.ProductServiceHandler(r.Context(), body)
+		if err != nil {
+			log.Printf("[/products] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
 		log.Printf("[/products] service done (elapsed=%s), rows=%d", time.Since(reqStart), len(data))
 
 		res.Json(w, data, http.StatusOK)
@@ -59,6 +66,31 @@ func (Controller *ProductController) GetProducts() http.HandlerFunc {
 	}
 }
 
+func (Controller *ProductController) GetPriceMatrix() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqStart := time.Now()
+		log.Printf("[/products/priceMatrix] start")
+
+		body, err := req.HandleBody[PriceMatrixDto](&w, r)
+		if err != nil {
+			log.Printf("[/products/priceMatrix] failed to parse body: %v (elapsed=%s)", err, time.Since(reqStart))
+			return
+		}
+		log.Printf("[/products/priceMatrix] body parsed (elapsed=%s), skus=%d, cardCodes=%d", time.Since(reqStart), len(body.Skus), len(body.CardCodes))
+
+		data, err := Controller.ProductService.PriceMatrix(r.Context(), body)
+		if err != nil {
+			log.Printf("[/products/priceMatrix] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
+		log.Printf("[/products/priceMatrix] service done (elapsed=%s), customers=%d, rows=%d", time.Since(reqStart), len(data.Customers), len(data.Rows))
+
+		res.Json(w, data, http.StatusOK)
+		log.Printf("[/products/priceMatrix] response sent (total=%s)", time.Since(reqStart))
+	}
+}
+
 func (Controller *ProductController) GetProductTree() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		reqStart := time.Now()
@@ -71,7 +103,26 @@ func (Controller *ProductController) GetProductTree() http.HandlerFunc {
 		}
 		log.Printf("[/productTree] body parsed (elapsed=%s), skus=%d", time.Since(reqStart), len(body.Skus))
 
-		data := Controller.ProductService.ProductTreeHandler(body)
+		if body.explode() {
+			data, err := Controller.ProductService.ExplodeBoms(r.Context(), body)
+			if err != nil {
+				log.Printf("[/productTree] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+				res.Error(w, err)
+				return
+			}
+			log.Printf("[/productTree] service done (elapsed=%s), trees=%d", time.Since(reqStart), len(data))
+
+			res.Json(w, data, http.StatusOK)
+			log.Printf("[/productTree] response sent (total=%s)", time.Since(reqStart))
+			return
+		}
+
+		data, err := Controller.ProductService.ProductTreeHandler(r.Context(), body)
+		if err != nil {
+			log.Printf("[/productTree] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
 		log.Printf("[/productTree] service done (elapsed=%s), headers=%d", time.Since(reqStart), len(data))
 
 		res.Json(w, data, http.StatusOK)
@@ -79,6 +130,31 @@ func (Controller *ProductController) GetProductTree() http.HandlerFunc {
 	}
 }
 
+func (Controller *ProductController) GetWhereUsed() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reqStart := time.Now()
+		log.Printf("[/productTree/whereUsed] start")
+
+		body, err := req.HandleBody[WhereUsedDto](&w, r)
+		if err != nil {
+			log.Printf("[/productTree/whereUsed] failed to parse body: %v (elapsed=%s)", err, time.Since(reqStart))
+			return
+		}
+		log.Printf("[/productTree/whereUsed] body parsed (elapsed=%s), codes=%d", time.Since(reqStart), len(body.Codes))
+
+		data, err := Controller.ProductService.WhereUsed(r.Context(), body)
+		if err != nil {
+			log.Printf("[/productTree/whereUsed] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
+		log.Printf("[/productTree/whereUsed] service done (elapsed=%s), codes=%d", time.Since(reqStart), len(data))
+
+		res.Json(w, data, http.StatusOK)
+		log.Printf("[/productTree/whereUsed] response sent (total=%s)", time.Since(reqStart))
+	}
+}
+
 func (Controller *ProductController) GetProductStcok() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		reqStart := time.Now()
@@ -91,7 +167,26 @@ func (Controller *ProductController) GetProductStcok() http.HandlerFunc {
 		}
 		log.Printf("[/productStock] body parsed (elapsed=%s), skus=%d", time.Since(reqStart), len(body.Skus))
 
-		data := Controller.ProductService.ProductStocks(body)
+		if body.perWarehouse() {
+			data, err := Controller.ProductService.ProductWarehouseStocks(r.Context(), body)
+			if err != nil {
+				log.Printf("[/productStock] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+				res.Error(w, err)
+				return
+			}
+			log.Printf("[/productStock] service done (elapsed=%s), rows=%d", time.Since(reqStart), len(data))
+
+			res.Json(w, data, http.StatusOK)
+			log.Printf("[/productStock] response sent (total=%s)", time.Since(reqStart))
+			return
+		}
+
+		data, err := Controller.ProductService.ProductStocks(r.Context(), body)
+		if err != nil {
+			log.Printf("[/productStock] service failed: %v (elapsed=%s)", err, time.Since(reqStart))
+			res.Error(w, err)
+			return
+		}
 		log.Printf("[/productStock] service done (elapsed=%s), rows=%d", time.Since(reqStart), len(data))
 
 		res.Json(w, data, http.StatusOK)
@@ -0,0 +1,193 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"sql-service/internal/pricing"
+	"sql-service/pkg/apperr"
+)
+
+// exchangeRate is the latest ORTT rate of a currency on or before the pricing date.
+type exchangeRate struct {
+	Rate float64
+	Date time.Time
+}
+
+// currencyConverter converts amounts through the local currency. With direct
+// quotation (OADM.DirectRate = 'Y') an ORTT rate is local units per foreign
+// unit, otherwise foreign units per local unit.
+type currencyConverter struct {
+	Local  string
+	Direct bool
+	Rates  map[string]exchangeRate
+}
+
+// GetLocalCurrency returns the company's local currency and quotation mode from OADM.
+func (r *ProductRepository) GetLocalCurrency(ctx context.Context) (string, bool, error) {
+	var (
+		local  sql.NullString
+		direct sql.NullString
+	)
+	err := r.Db.QueryRowContext(ctx, `SELECT TOP 1 MainCurncy, DirectRate FROM OADM WITH (NOLOCK)`).Scan(&local, &direct)
+	if err != nil {
+		return "", false, apperr.Upstream("company currency query failed", err)
+	}
+	return strings.TrimSpace(local.String), direct.String != "N", nil
+}
+
+// GetExchangeRates returns the latest positive ORTT rate on or before date of
+// every currency that has one.
+func (r *ProductRepository) GetExchangeRates(ctx context.Context, currencies []string, date time.Time) (map[string]exchangeRate, error) {
+	rates := make(map[string]exchangeRate, len(currencies))
+	if len(currencies) == 0 {
+		return rates, nil
+	}
+
+	list, args := namedList("cur", currencies)
+	args = append(args, sql.Named("rateDate", date))
+	query := fmt.Sprintf(`
+SELECT R.Currency, R.Rate, R.RateDate
+FROM ORTT AS R WITH (NOLOCK)
+WHERE R.Currency IN (%s)
+  AND R.RateDate = (
+        SELECT MAX(R2.RateDate)
+        FROM ORTT AS R2 WITH (NOLOCK)
+        WHERE R2.Currency = R.Currency
+          AND R2.RateDate <= @rateDate
+          AND R2.Rate > 0
+  )`, list)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("exchange rates query failed", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			currency string
+			rate     exchangeRate
+		)
+		if err := rows.Scan(&currency, &rate.Rate, &rate.Date); err != nil {
+			return nil, apperr.Upstream("exchange rates query failed", err)
+		}
+		rates[strings.ToUpper(strings.TrimSpace(currency))] = rate
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("exchange rates query failed", err)
+	}
+	return rates, nil
+}
+
+// newCurrencyConverter loads the rates needed to convert every currency of
+// products to target, failing when one of them has no rate yet on date.
+func (service *ProductService) newCurrencyConverter(ctx context.Context, products []Product, target string, date time.Time) (*currencyConverter, error) {
+	local, direct, err := service.productRepository.GetLocalCurrency(ctx)
+	if err != nil {
+		return nil, err
+	}
+	converter := &currencyConverter{Local: strings.ToUpper(local), Direct: direct}
+
+	needed := map[string]bool{}
+	for _, currency := range append([]string{target}, productCurrencies(products)...) {
+		if currency != converter.Local {
+			needed[currency] = true
+		}
+	}
+	currencies := make([]string, 0, len(needed))
+	for currency := range needed {
+		currencies = append(currencies, currency)
+	}
+
+	converter.Rates, err = service.productRepository.GetExchangeRates(ctx, currencies, date)
+	if err != nil {
+		return nil, err
+	}
+	for _, currency := range currencies {
+		if _, ok := converter.Rates[currency]; !ok {
+			return nil, apperr.Validation("exchange rate missing",
+				fmt.Sprintf("no ORTT rate for %s on or before %s", currency, date.Format("2006-01-02")))
+		}
+	}
+	return converter, nil
+}
+
+// convert returns the factor turning one unit of from into to, and the date
+// of the oldest rate it relies on, nil when no rate is involved.
+func (c *currencyConverter) convert(from, to string) (float64, *time.Time) {
+	factor := 1.0
+	var rateDate *time.Time
+	use := func(currency string) float64 {
+		rate := c.Rates[currency]
+		if rateDate == nil || rate.Date.Before(*rateDate) {
+			date := rate.Date
+			rateDate = &date
+		}
+		return rate.Rate
+	}
+
+	if from == to {
+		return factor, nil
+	}
+	if from != c.Local {
+		if c.Direct {
+			factor *= use(from)
+		} else {
+			factor /= use(from)
+		}
+	}
+	if to != c.Local {
+		if c.Direct {
+			factor /= use(to)
+		} else {
+			factor *= use(to)
+		}
+	}
+	return factor, rateDate
+}
+
+// convertProducts moves PriceListPrice and FinalPrice into target, the other
+// amounts stay in the price list currency reported by Conversion.From.
+func (c *currencyConverter) convertProducts(products []Product, target string) {
+	for i := range products {
+		p := &products[i]
+		from := c.Local
+		if p.Currency.Valid && strings.TrimSpace(p.Currency.String) != "" {
+			from = strings.ToUpper(strings.TrimSpace(p.Currency.String))
+		}
+
+		rate, rateDate := c.convert(from, target)
+		conversion := &CurrencyConversion{From: from, To: target, Rate: rate}
+		if rateDate != nil {
+			date := rateDate.Format("2006-01-02")
+			conversion.RateDate = &date
+		}
+
+		if p.PriceListPrice.Valid {
+			p.PriceListPrice.Float64 = pricing.Round(p.PriceListPrice.Float64 * rate)
+		}
+		p.FinalPrice = pricing.Round(p.FinalPrice * rate)
+		p.Currency = MyNullString{sql.NullString{String: target, Valid: true}}
+		p.Conversion = conversion
+	}
+}
+
+func productCurrencies(products []Product) []string {
+	seen := map[string]bool{}
+	currencies := make([]string, 0)
+	for _, p := range products {
+		if !p.Currency.Valid {
+			continue
+		}
+		currency := strings.ToUpper(strings.TrimSpace(p.Currency.String))
+		if currency != "" && !seen[currency] {
+			seen[currency] = true
+			currencies = append(currencies, currency)
+		}
+	}
+	return currencies
+}
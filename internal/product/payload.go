@@ -1,25 +1,77 @@
 package product
 
-import "time"
+import (
+	"time"
 
+	"sql-service/internal/pricing"
+)
+
+// ProductsDto prices Skus, which may mix ItemCodes, barcodes and the
+// customer's catalog numbers.
 type ProductsDto struct {
 	Skus      []string `json:"skus" validate:"required,min=1,dive,required"`
 	PriceList *string  `json:"priceList,omitempty"`
 	Warehouse string   `json:"warehouse" validate:"required"`
 	CardCode  string   `json:"cardCode" validate:"required"`
 	Date      string   `json:"date" validate:"required"`
+	// Quantities maps a sku to the quantity priced for SPP2 quantity breaks, 1 when missing.
+	Quantities map[string]float64 `json:"quantities,omitempty"`
+	// TargetCurrency converts PriceListPrice and FinalPrice with the ORTT rates of Date.
+	TargetCurrency string `json:"targetCurrency,omitempty"`
+	Explain        bool   `json:"explain,omitempty"`
+}
+
+// CurrencyConversion reports how a product's prices were converted. RateDate
+// is the date of the oldest ORTT rate used, nil when no rate was needed.
+type CurrencyConversion struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Rate     float64 `json:"rate"`
+	RateDate *string `json:"rateDate"`
+}
+
+type PriceMatrixDto struct {
+	Skus          []string           `json:"skus" validate:"required,min=1,dive,required"`
+	CardCodes     []string           `json:"cardCodes,omitempty"`
+	CustomerGroup *int               `json:"customerGroup,omitempty"`
+	Date          string             `json:"date" validate:"required"`
+	Quantities    map[string]float64 `json:"quantities,omitempty"`
 }
 
+// ProductSkusStockDto asks for the stock of a single Warehouse, or per
+// warehouse when Warehouses or AllWarehouses is set. Atp adds the projection
+// of open sales and purchase orders to the per warehouse stock. CardCode
+// lets Skus hold that customer's catalog numbers.
 type ProductSkusStockDto struct {
-	Skus      []string `json:"skus" validate:"required,min=1,dive,required"`
-	Warehouse string   `json:"warehouse" validate:"required"`
+	Skus          []string `json:"skus" validate:"required,min=1,dive,required"`
+	CardCode      string   `json:"cardCode,omitempty"`
+	Warehouse     string   `json:"warehouse,omitempty"`
+	Warehouses    []string `json:"warehouses,omitempty"`
+	AllWarehouses bool     `json:"allWarehouses,omitempty"`
+	Atp           bool     `json:"atp,omitempty"`
+}
+
+func (dto *ProductSkusStockDto) perWarehouse() bool {
+	return dto.AllWarehouses || len(dto.Warehouses) > 0
 }
 
+// ProductSkusDto lists the trees to load. Depth above 1, a Mode or a
+// CostPriceList switch /productTree to the recursive explosion. CardCode
+// lets Skus hold that customer's catalog numbers.
 type ProductSkusDto struct {
-	Skus []string `json:"skus" validate:"required,min=1,dive,required"`
+	Skus          []string `json:"skus" validate:"required,min=1,dive,required"`
+	CardCode      string   `json:"cardCode,omitempty"`
+	Depth         int      `json:"depth,omitempty"`
+	Mode          string   `json:"mode,omitempty"`
+	CostPriceList *int     `json:"costPriceList,omitempty"`
+}
+
+func (dto *ProductSkusDto) explode() bool {
+	return dto.Depth > 1 || dto.Mode != "" || dto.CostPriceList != nil
 }
 
 type Product struct {
+	Input                string        `json:"input,omitempty"`
 	SKU                  string        `json:"sku"`
 	CardCode             string        `json:"cardCode"`
 	PriceList            MyNullFloat64 `json:"priceList"`
@@ -40,9 +92,43 @@ type Product struct {
 	Commited             MyNullFloat64 `json:"commited"`
 	PriceSource          string        `json:"priceSource"`
 	FinalPrice           float64       `json:"finalPrice"`
+
+	Quantity   float64              `json:"quantity"`
+	PriceTier  *pricing.PriceTier   `json:"priceTier"`
+	NextBreak  *pricing.PriceBreak  `json:"nextBreak"`
+	Conversion *CurrencyConversion  `json:"conversion,omitempty"`
+	Explain    *pricing.Explanation `json:"explain,omitempty"`
+}
+
+type PriceMatrix struct {
+	Date             string                `json:"date"`
+	Customers        []PriceMatrixCustomer `json:"customers"`
+	MissingCustomers []string              `json:"missingCustomers"`
+	Rows             []PriceMatrixRow      `json:"rows"`
+}
+
+type PriceMatrixCustomer struct {
+	CardCode     string `json:"cardCode"`
+	PriceList    int    `json:"priceList"`
+	GroupCode    int    `json:"groupCode"`
+	DiscountMode string `json:"discountMode"`
+}
+
+// PriceMatrixRow holds the price of one sku for every customer that has it on
+// its price list, keyed by CardCode.
+type PriceMatrixRow struct {
+	SKU    string                     `json:"sku"`
+	Prices map[string]PriceMatrixCell `json:"prices"`
+}
+
+type PriceMatrixCell struct {
+	Currency    *string `json:"currency"`
+	FinalPrice  float64 `json:"finalPrice"`
+	PriceSource string  `json:"priceSource"`
 }
 
 type ProductStock struct {
+	Input         string        `json:"input,omitempty"`
 	SKU           string        `json:"sku"`
 	WarehouseCode string        `json:"warehouseCode"`
 	Stock         MyNullFloat64 `json:"stock"`
@@ -50,7 +136,91 @@ type ProductStock struct {
 	Commited      MyNullFloat64 `json:"commited"`
 }
 
+type ProductWarehouseStock struct {
+	Input      string           `json:"input,omitempty"`
+	SKU        string           `json:"sku"`
+	IsKit      bool             `json:"isKit"`
+	Warehouses []WarehouseStock `json:"warehouses"`
+}
+
+// WarehouseStock is the stock of an item in one warehouse, a kit reports the
+// row of its child with the lowest on hand quantity.
+type WarehouseStock struct {
+	WarehouseCode string     `json:"warehouseCode"`
+	OnHand        float64    `json:"onHand"`
+	Commited      float64    `json:"commited"`
+	OnOrder       float64    `json:"onOrder"`
+	Available     float64    `json:"available"`
+	Atp           []AtpPoint `json:"atp,omitempty"`
+}
+
+// AtpPoint is the projected quantity after the open order lines due on Date.
+type AtpPoint struct {
+	Date      string  `json:"date"`
+	Receipts  float64 `json:"receipts"`
+	Issues    float64 `json:"issues"`
+	Available float64 `json:"available"`
+}
+
+type WhereUsedDto struct {
+	Codes []string `json:"codes" validate:"required,min=1,dive,required"`
+	Depth int      `json:"depth,omitempty"`
+}
+
+type WhereUsed struct {
+	Code    string     `json:"code"`
+	Parents []BomUsage `json:"parents"`
+}
+
+// BomUsage is a tree containing the component, Level steps up. Path runs from
+// the component to Parent and Quantities holds the quantity of each step per
+// unit of its father; Quantity is their product.
+type BomUsage struct {
+	Parent     string    `json:"parent"`
+	ParentName *string   `json:"parentName"`
+	TreeType   string    `json:"treeType"`
+	Level      int       `json:"level"`
+	Path       []string  `json:"path"`
+	Quantities []float64 `json:"quantities"`
+	Quantity   float64   `json:"quantity"`
+	TopLevel   bool      `json:"topLevel"`
+	Cycle      bool      `json:"cycle,omitempty"`
+}
+
+// BomExplosion is a tree exploded for one unit of Code. Components is nested,
+// or flat in preorder with Level and Path when the flat mode is requested.
+type BomExplosion struct {
+	Input      string         `json:"input,omitempty"`
+	Code       string         `json:"code"`
+	Name       *string        `json:"name"`
+	TreeType   string         `json:"treeType"`
+	Quantity   float64        `json:"quantity"`
+	Depth      int            `json:"depth"`
+	Components []BomComponent `json:"components"`
+	Cost       *float64       `json:"cost,omitempty"`
+	Currency   *string        `json:"currency,omitempty"`
+	Warnings   []string       `json:"warnings,omitempty"`
+}
+
+type BomComponent struct {
+	Code          string         `json:"code"`
+	ItemName      *string        `json:"itemName"`
+	Level         int            `json:"level"`
+	Path          []string       `json:"path"`
+	Quantity      float64        `json:"quantity"`
+	TotalQuantity float64        `json:"totalQuantity"`
+	Warehouse     *string        `json:"warehouse"`
+	IssueMthd     *string        `json:"issueMthd"`
+	TreeType      *string        `json:"treeType"`
+	Cycle         bool           `json:"cycle,omitempty"`
+	UnitPrice     *float64       `json:"unitPrice,omitempty"`
+	Currency      *string        `json:"currency,omitempty"`
+	Cost          *float64       `json:"cost,omitempty"`
+	Components    []BomComponent `json:"components,omitempty"`
+}
+
 type BomHeaderDTO struct {
+	Input                string     `json:"input,omitempty"`
 	Code                 string     `json:"Code"`
 	TreeType             string     `json:"TreeType"`
 	PriceList            *int64     `json:"PriceList,omitempty"`
@@ -0,0 +1,364 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"sql-service/internal/pricing"
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/db"
+)
+
+// GetPricingCustomers loads the OCRD pricing settings of the given customers.
+// Customers without a price list cannot be priced and are left out.
+func (r *ProductRepository) GetPricingCustomers(ctx context.Context, cardCodes []string) ([]pricing.Customer, error) {
+	if len(cardCodes) == 0 {
+		return []pricing.Customer{}, nil
+	}
+
+	list, args := namedList("card", cardCodes)
+	return r.getPricingCustomers(ctx, fmt.Sprintf("CardCode IN (%s)", list), args)
+}
+
+// GetPricingCustomersByGroup loads the pricing settings of the customers of an
+// OCRG customer group.
+func (r *ProductRepository) GetPricingCustomersByGroup(ctx context.Context, groupCode int) ([]pricing.Customer, error) {
+	return r.getPricingCustomers(ctx, "GroupCode = @groupCode AND CardType = 'C'", []any{sql.Named("groupCode", groupCode)})
+}
+
+func (r *ProductRepository) getPricingCustomers(ctx context.Context, where string, args []any) ([]pricing.Customer, error) {
+	query := fmt.Sprintf(`
+SELECT CardCode, ListNum, GroupCode, DiscRel
+FROM OCRD WITH (NOLOCK)
+WHERE %s
+  AND ListNum IS NOT NULL
+ORDER BY CardCode`, where)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("pricing customers query failed", err)
+	}
+	defer rows.Close()
+
+	customers := make([]pricing.Customer, 0)
+	for rows.Next() {
+		var (
+			customer  pricing.Customer
+			groupCode sql.NullInt64
+			discRel   sql.NullString
+		)
+		if err := rows.Scan(&customer.CardCode, &customer.ListNum, &groupCode, &discRel); err != nil {
+			return nil, apperr.Upstream("pricing customers query failed", err)
+		}
+		customer.GroupCode = int(groupCode.Int64)
+		customer.DiscRel = discRel.String
+		customers = append(customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("pricing customers query failed", err)
+	}
+
+	return customers, nil
+}
+
+// GetPricingData loads the raw price list, special price and discount group
+// rows needed to price skus for the given customers.
+func (r *ProductRepository) GetPricingData(ctx context.Context, customers []pricing.Customer, skus []string) (pricing.Data, error) {
+	start := time.Now()
+	data := pricing.Data{
+		Items:         []pricing.ItemPrice{},
+		SpecialPrices: []pricing.SpecialPrice{},
+		Periods:       []pricing.SpecialPeriod{},
+		VolumeBreaks:  []pricing.VolumeBreak{},
+		Rules:         []pricing.DiscountRule{},
+	}
+	if len(customers) == 0 || len(skus) == 0 {
+		return data, nil
+	}
+
+	var (
+		cardCodes  []string
+		groupCodes []string
+		listNums   []string
+	)
+	seenGroups := map[int]bool{}
+	seenLists := map[int]bool{}
+	for _, customer := range customers {
+		cardCodes = append(cardCodes, customer.CardCode)
+		if !seenGroups[customer.GroupCode] {
+			seenGroups[customer.GroupCode] = true
+			groupCodes = append(groupCodes, strconv.Itoa(customer.GroupCode))
+		}
+		if !seenLists[customer.ListNum] {
+			seenLists[customer.ListNum] = true
+			listNums = append(listNums, strconv.Itoa(customer.ListNum))
+		}
+	}
+
+	var err error
+	if data.Items, err = r.getPricingItems(ctx, skus, listNums); err != nil {
+		return pricing.Data{}, err
+	}
+	if data.SpecialPrices, err = r.getSpecialPrices(ctx, cardCodes, skus); err != nil {
+		return pricing.Data{}, err
+	}
+	if data.Periods, data.VolumeBreaks, err = r.getSpecialPriceTiers(ctx, cardCodes, skus); err != nil {
+		return pricing.Data{}, err
+	}
+	if data.Rules, err = r.getDiscountRules(ctx, cardCodes, groupCodes, skus); err != nil {
+		return pricing.Data{}, err
+	}
+
+	log.Printf("GetPricingData: customers=%d, skus=%d, items=%d, specialPrices=%d, periods=%d, breaks=%d, rules=%d, took=%s",
+		len(customers), len(skus), len(data.Items), len(data.SpecialPrices), len(data.Periods), len(data.VolumeBreaks),
+		len(data.Rules), time.Since(start))
+	return data, nil
+}
+
+func (r *ProductRepository) getPricingItems(ctx context.Context, skus []string, listNums []string) ([]pricing.ItemPrice, error) {
+	skuList, args := namedList("sku", skus)
+	query := fmt.Sprintf(`
+SELECT I.ItemCode, I.FirmCode, I.ItmsGrpCod, P.PriceList, P.Price, P.Currency
+FROM OITM AS I WITH (NOLOCK)
+INNER JOIN ITM1 AS P WITH (NOLOCK)
+    ON P.ItemCode = I.ItemCode
+WHERE I.ItemCode IN (%s)
+  AND P.PriceList IN (%s)`, skuList, strings.Join(listNums, ", "))
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("price list query failed", err)
+	}
+	defer rows.Close()
+
+	items := make([]pricing.ItemPrice, 0, len(skus))
+	for rows.Next() {
+		var (
+			item      pricing.ItemPrice
+			firmCode  sql.NullInt64
+			itemGroup sql.NullInt64
+			price     sql.NullFloat64
+			currency  sql.NullString
+		)
+		if err := rows.Scan(&item.ItemCode, &firmCode, &itemGroup, &item.PriceList, &price, &currency); err != nil {
+			return nil, apperr.Upstream("price list query failed", err)
+		}
+		item.FirmCode = db.IntPtr(firmCode)
+		item.ItmsGrpCod = db.IntPtr(itemGroup)
+		item.Price = db.FloatPtr(price)
+		item.Currency = db.StringPtr(currency)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("price list query failed", err)
+	}
+	return items, nil
+}
+
+func (r *ProductRepository) getSpecialPrices(ctx context.Context, cardCodes []string, skus []string) ([]pricing.SpecialPrice, error) {
+	cardList, args := namedList("card", cardCodes)
+	skuList, skuArgs := namedList("sku", skus)
+	args = append(args, skuArgs...)
+
+	query := fmt.Sprintf(`
+SELECT CardCode, ItemCode, ListNum, Price, Discount, Valid, ValidFrom, ValidTo
+FROM OSPP WITH (NOLOCK)
+WHERE CardCode IN (%s)
+  AND ItemCode IN (%s)`, cardList, skuList)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("special prices query failed", err)
+	}
+	defer rows.Close()
+
+	specialPrices := make([]pricing.SpecialPrice, 0)
+	for rows.Next() {
+		var (
+			row       pricing.SpecialPrice
+			listNum   sql.NullInt64
+			price     sql.NullFloat64
+			discount  sql.NullFloat64
+			valid     sql.NullString
+			validFrom sql.NullTime
+			validTo   sql.NullTime
+		)
+		if err := rows.Scan(&row.CardCode, &row.ItemCode, &listNum, &price, &discount, &valid, &validFrom, &validTo); err != nil {
+			return nil, apperr.Upstream("special prices query failed", err)
+		}
+		row.ListNum = db.IntPtr(listNum)
+		row.Price = db.FloatPtr(price)
+		row.Discount = db.FloatPtr(discount)
+		row.Valid = valid.String
+		row.ValidFrom = db.TimePtr(validFrom)
+		row.ValidTo = db.TimePtr(validTo)
+		specialPrices = append(specialPrices, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("special prices query failed", err)
+	}
+	return specialPrices, nil
+}
+
+// getSpecialPriceTiers loads the SPP1 periods and SPP2 quantity breaks of the
+// customers' special prices.
+func (r *ProductRepository) getSpecialPriceTiers(ctx context.Context, cardCodes []string, skus []string) ([]pricing.SpecialPeriod, []pricing.VolumeBreak, error) {
+	cardList, args := namedList("card", cardCodes)
+	skuList, skuArgs := namedList("sku", skus)
+	args = append(args, skuArgs...)
+
+	periodsQuery := fmt.Sprintf(`
+SELECT CardCode, ItemCode, LINENUM, FromDate, ToDate, Price, Discount
+FROM SPP1 WITH (NOLOCK)
+WHERE CardCode IN (%s)
+  AND ItemCode IN (%s)
+ORDER BY CardCode, ItemCode, LINENUM`, cardList, skuList)
+
+	rows, err := r.Db.QueryContext(ctx, periodsQuery, args...)
+	if err != nil {
+		return nil, nil, apperr.Upstream("special price periods query failed", err)
+	}
+	defer rows.Close()
+
+	periods := make([]pricing.SpecialPeriod, 0)
+	for rows.Next() {
+		var (
+			period   pricing.SpecialPeriod
+			fromDate sql.NullTime
+			toDate   sql.NullTime
+			price    sql.NullFloat64
+			discount sql.NullFloat64
+		)
+		if err := rows.Scan(&period.CardCode, &period.ItemCode, &period.LineNum, &fromDate, &toDate, &price, &discount); err != nil {
+			return nil, nil, apperr.Upstream("special price periods query failed", err)
+		}
+		period.FromDate = db.TimePtr(fromDate)
+		period.ToDate = db.TimePtr(toDate)
+		period.Price = db.FloatPtr(price)
+		period.Discount = db.FloatPtr(discount)
+		periods = append(periods, period)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, apperr.Upstream("special price periods query failed", err)
+	}
+	if len(periods) == 0 {
+		return periods, []pricing.VolumeBreak{}, nil
+	}
+
+	breaksQuery := fmt.Sprintf(`
+SELECT CardCode, ItemCode, SPP1LNum, Amount, Price, Discount
+FROM SPP2 WITH (NOLOCK)
+WHERE CardCode IN (%s)
+  AND ItemCode IN (%s)
+ORDER BY CardCode, ItemCode, SPP1LNum, Amount`, cardList, skuList)
+
+	breakRows, err := r.Db.QueryContext(ctx, breaksQuery, args...)
+	if err != nil {
+		return nil, nil, apperr.Upstream("special price quantity breaks query failed", err)
+	}
+	defer breakRows.Close()
+
+	breaks := make([]pricing.VolumeBreak, 0)
+	for breakRows.Next() {
+		var (
+			row      pricing.VolumeBreak
+			price    sql.NullFloat64
+			discount sql.NullFloat64
+		)
+		if err := breakRows.Scan(&row.CardCode, &row.ItemCode, &row.PeriodLine, &row.Amount, &price, &discount); err != nil {
+			return nil, nil, apperr.Upstream("special price quantity breaks query failed", err)
+		}
+		row.Price = db.FloatPtr(price)
+		row.Discount = db.FloatPtr(discount)
+		breaks = append(breaks, row)
+	}
+	if err := breakRows.Err(); err != nil {
+		return nil, nil, apperr.Upstream("special price quantity breaks query failed", err)
+	}
+
+	return periods, breaks, nil
+}
+
+// getDiscountRules loads the EDG1 lines of the customers', their groups',
+// the global and the promotional discount groups. Item lines are limited to
+// skus; manufacturer and item group lines are few and loaded whole.
+func (r *ProductRepository) getDiscountRules(ctx context.Context, cardCodes, groupCodes, skus []string) ([]pricing.DiscountRule, error) {
+	cardList, args := namedList("card", cardCodes)
+	groupList, groupArgs := namedList("grp", groupCodes)
+	skuList, skuArgs := namedList("sku", skus)
+	args = append(append(args, groupArgs...), skuArgs...)
+
+	query := fmt.Sprintf(`
+SELECT
+    E.AbsEntry, E.Type, E.ObjType, E.ObjCode, E.ValidFor, E.ValidForm, E.ValidTo,
+    E1.ObjType AS LineObjType, E1.ObjKey, E1.Discount
+FROM OEDG AS E WITH (NOLOCK)
+INNER JOIN EDG1 AS E1 WITH (NOLOCK)
+    ON E1.AbsEntry = E.AbsEntry
+WHERE E1.ObjType IN ('4','43','52')
+  AND (E1.ObjType <> '4' OR E1.ObjKey IN (%[3]s))
+  AND (
+        (E.Type = 'S' AND E.ObjCode IN (%[1]s))
+     OR (E.Type = 'C' AND E.ObjCode IN (%[2]s))
+     OR (E.ObjType = '-1' AND E.ObjCode = '0')
+     OR (E.Type = 'A' AND E1.ObjType = '4')
+  )
+ORDER BY E.AbsEntry`, cardList, groupList, skuList)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("discount groups query failed", err)
+	}
+	defer rows.Close()
+
+	rules := make([]pricing.DiscountRule, 0)
+	for rows.Next() {
+		var (
+			rule      pricing.DiscountRule
+			ruleType  sql.NullString
+			objType   sql.NullString
+			objCode   sql.NullString
+			validFor  sql.NullString
+			validFrom sql.NullTime
+			validTo   sql.NullTime
+			lineType  sql.NullString
+			objKey    sql.NullString
+			discount  sql.NullFloat64
+		)
+		if err := rows.Scan(&rule.AbsEntry, &ruleType, &objType, &objCode, &validFor, &validFrom, &validTo,
+			&lineType, &objKey, &discount); err != nil {
+			return nil, apperr.Upstream("discount groups query failed", err)
+		}
+		rule.Type = ruleType.String
+		rule.ObjType = objType.String
+		rule.ObjCode = objCode.String
+		rule.ValidFor = validFor.String
+		rule.ValidFrom = db.TimePtr(validFrom)
+		rule.ValidTo = db.TimePtr(validTo)
+		rule.LineObjType = lineType.String
+		rule.ObjKey = objKey.String
+		rule.Discount = db.FloatPtr(discount)
+		rules = append(rules, rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("discount groups query failed", err)
+	}
+	return rules, nil
+}
+
+// namedList renders values as @prefix0, @prefix1, ... for an IN list.
+func namedList(prefix string, values []string) (string, []any) {
+	names := make([]string, len(values))
+	args := make([]any, len(values))
+	for i, value := range values {
+		name := fmt.Sprintf("%s%d", prefix, i)
+		names[i] = "@" + name
+		args[i] = sql.Named(name, value)
+	}
+	return strings.Join(names, ", "), args
+}
@@ -1,6 +1,7 @@
 package product
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"sql-service/pkg/apperr"
 	"sql-service/pkg/db"
 )
 
@@ -42,557 +44,12 @@ type ProductRepository struct{ Db *db.Db }
 
 func NewProductRepository(db *db.Db) *ProductRepository { return &ProductRepository{Db: db} }
 
-func (r *ProductRepository) GetProducts(dto *ProductsDto) ([]Product, error) {
-	totalStart := time.Now()
-	log.Printf("GetProducts: start, skus=%d, cardCode=%s, warehouse=%s, date=%s",
-		len(dto.Skus), dto.CardCode, dto.Warehouse, dto.Date)
-
-	if len(dto.Skus) == 0 {
-		return nil, fmt.Errorf("sku list cannot be empty")
-	}
-
-	unionParts := make([]string, 0, len(dto.Skus))
-	args := []any{
-		sql.Named("cardCode", dto.CardCode),
-		sql.Named("userExtId", dto.CardCode),
-		sql.Named("asOfDate", dto.Date),
-		sql.Named("warehouse", dto.Warehouse),
-	}
-
-	for i, sku := range dto.Skus {
-		name := fmt.Sprintf("sku%d", i)
-		if i == 0 {
-			unionParts = append(unionParts, fmt.Sprintf("SELECT @%s AS sku", name))
-		} else {
-			unionParts = append(unionParts, fmt.Sprintf("UNION ALL SELECT @%s", name))
-		}
-		args = append(args, sql.Named(name, sku))
-	}
-	skuUnion := strings.Join(unionParts, "\n        ")
-
-	query := fmt.Sprintf(`
-WITH SkuList AS (
-        %s
-),
-Cust AS (
-    SELECT TOP 1 T5.ListNum
-    FROM OCRD AS T5 WITH (NOLOCK)
-    WHERE T5.CardCode = @cardCode
-),
-CustGroup AS (
-    SELECT TOP 1 GroupCode
-    FROM OCRD WITH (NOLOCK)
-    WHERE CardCode = @cardCode
-),
-BasePrice AS (
-    SELECT
-        OITM.ItemCode,
-        OITM.FirmCode,
-        ITM1.Price    AS PriceListPrice,
-        ITM1.Currency AS Currency
-    FROM OITM WITH (NOLOCK)
-    INNER JOIN ITM1 WITH (NOLOCK)
-        ON OITM.ItemCode = ITM1.ItemCode
-    CROSS JOIN Cust
-    WHERE ITM1.PriceList = Cust.ListNum
-      AND OITM.ItemCode IN (SELECT sku FROM SkuList)
-),
-SpecialPrice AS (
-    SELECT
-        P.ItemCode,
-        P.ListNum,
-        P.Price    AS OSPPPrice,
-        P.Discount AS OSPPDiscount
-    FROM OSPP AS P WITH (NOLOCK)
-    WHERE P.CardCode = @cardCode
-      AND P.Valid = 'Y'
-      AND (
-            (P.ValidFrom IS NULL OR P.ValidFrom <= @asOfDate)
-        AND (P.ValidTo   IS NULL OR P.ValidTo   >= @asOfDate)
-      )
-),
-AllDiscountRules AS (
-    SELECT
-        BP.ItemCode,
-        E.Type                   AS RuleType,
-        E1.ObjType,
-        E1.ObjKey,
-        E1.Discount              AS DiscountPct,
-        CASE E1.ObjType
-            WHEN '4'  THEN N'Discount group (item)'
-            WHEN '43' THEN N'Discount group (manufacturer)'
-            WHEN '52' THEN N'Discount group (item group)'
-        END                      AS RuleSource
-    FROM BasePrice AS BP
-    CROSS JOIN CustGroup
-    INNER JOIN OEDG AS E WITH (NOLOCK)
-       ON (
-             (E.Type = 'S' AND E.ObjCode = @cardCode)
-          OR (E.Type = 'C' AND E.ObjCode = CONVERT(NVARCHAR, CustGroup.GroupCode))
-          OR (E.ObjType = '-1' AND E.ObjCode = '0') -- global rule
-          )
-       AND (
-            E.ValidFor = 'Y'
-            AND (
-                    (E.ValidForm IS NULL OR E.ValidForm <= @asOfDate)
-                AND (E.ValidTo   IS NULL OR E.ValidTo   >= @asOfDate)
-            )
-       )
-    INNER JOIN EDG1 AS E1 WITH (NOLOCK)
-        ON E1.AbsEntry = E.AbsEntry
-       AND E1.ObjType IN ('4','43','52')
-    LEFT JOIN OITM WITH (NOLOCK)
-        ON OITM.ItemCode = BP.ItemCode
-    WHERE
-          (E1.ObjType = '4'  AND E1.ObjKey = BP.ItemCode)
-       OR (E1.ObjType = '43' AND TRY_CAST(E1.ObjKey AS INT) = BP.FirmCode)
-       OR (E1.ObjType = '52' AND TRY_CAST(E1.ObjKey AS INT) = OITM.ItmsGrpCod)
-),
-
--- BP discount mode from OCRD.DiscRel (S/A/H/L/M)
-BpDiscountMode AS (
-    SELECT
-        ISNULL(NULLIF(C.DiscRel, ''), 'H') AS DiscountMode
-    FROM OCRD AS C WITH (NOLOCK)
-    WHERE C.CardCode = @cardCode
-),
-
--- Aggregate all discount rules per item according to DiscountMode
-BestDiscountPerItem AS (
-    SELECT
-        R.ItemCode,
-        Mode.DiscountMode,
-        CASE Mode.DiscountMode
-            WHEN 'H' THEN MAX(R.DiscountPct)
-            WHEN 'L' THEN MIN(R.DiscountPct)
-            WHEN 'A' THEN AVG(R.DiscountPct)
-            WHEN 'S' THEN
-                CASE
-                    WHEN SUM(R.DiscountPct) > 100.0 THEN 100.0
-                    ELSE SUM(R.DiscountPct)
-                END
-            WHEN 'M' THEN
-                CASE
-                    -- if any rule has discount >= 100 → effective discount = 100
-                    WHEN MAX(CASE WHEN R.DiscountPct >= 100 THEN 1 ELSE 0 END) = 1
-                        THEN 100.0
-                    ELSE
-                        100.0 * (
-                            1.0 - EXP(
-                                SUM(
-                                    CASE
-                                        WHEN R.DiscountPct IS NULL OR R.DiscountPct >= 100
-                                            THEN 0.0
-                                        ELSE LOG((100.0 - R.DiscountPct) / 100.0)
-                                    END
-                                )
-                            )
-                        )
-                END
-            ELSE MAX(R.DiscountPct)
-        END AS DiscountPct
-    FROM AllDiscountRules AS R
-    CROSS JOIN BpDiscountMode AS Mode
-    GROUP BY R.ItemCode, Mode.DiscountMode
-),
-
-DiscountRuleType AS (
-    SELECT
-        R.ItemCode,
-        R.RuleType,
-        ROW_NUMBER() OVER (
-            PARTITION BY R.ItemCode
-            ORDER BY
-                CASE
-                    WHEN Mode.DiscountMode = 'L' THEN R.DiscountPct
-                    ELSE -R.DiscountPct
-                END ASC,
-                R.RuleType
-        ) AS rn
-    FROM AllDiscountRules AS R
-    CROSS JOIN BpDiscountMode AS Mode
-),
-
-PromoDiscount AS (
-    SELECT
-        I.ItemCode,
-        E.Type AS PromoType,
-        E1.Discount AS PromoDiscount
-    FROM OEDG AS E WITH (NOLOCK)
-    INNER JOIN EDG1 AS E1 WITH (NOLOCK)
-        ON E1.AbsEntry = E.AbsEntry
-       AND E1.ObjType = '4'
-    INNER JOIN OITM AS I WITH (NOLOCK)
-        ON I.ItemCode = E1.ObjKey
-    WHERE E.Type = 'A'
-      AND (
-            E.ValidFor = 'Y'
-        AND (
-                (E.ValidForm IS NULL OR E.ValidForm <= @asOfDate)
-            AND (E.ValidTo   IS NULL OR E.ValidTo   >= @asOfDate)
-        )
-      )
-),
-
--- === BOM / stock logic (MIN stock of children) ===
-TreeParents AS (
-    SELECT S.sku AS ParentCode
-    FROM SkuList AS S
-    INNER JOIN OITT AS H WITH (NOLOCK)
-        ON H.Code = S.sku
-       AND H.TreeType = 'S'
-),
-ParentChildren AS (
-    SELECT H.Code AS ParentCode,
-           L.Code AS ChildCode
-    FROM OITT AS H WITH (NOLOCK)
-    INNER JOIN ITT1 AS L WITH (NOLOCK)
-        ON L.Father = H.Code
-    WHERE H.TreeType = 'S'
-      AND H.Code IN (SELECT sku FROM SkuList)
-),
-AllItemsForStock AS (
-    -- Non-tree parents: use own stock
-    SELECT S.sku AS ParentCode,
-           S.sku AS ItemCodeToCheck
-    FROM SkuList AS S
-    WHERE S.sku NOT IN (SELECT ParentCode FROM TreeParents)
-
-    UNION ALL
-
-    -- Tree parents: use children
-    SELECT C.ParentCode,
-           C.ChildCode AS ItemCodeToCheck
-    FROM ParentChildren AS C
-),
-StockRaw AS (
-    SELECT
-        W.ItemCode,
-        W.WhsCode,
-        W.OnHand,
-        W.OnOrder,
-        W.IsCommited
-    FROM OITW AS W WITH (NOLOCK)
-    WHERE W.WhsCode = @warehouse
-      AND W.ItemCode IN (SELECT ItemCodeToCheck FROM AllItemsForStock)
-),
-StockPerParentRows AS (
-    SELECT
-        A.ParentCode,
-        S.ItemCode,
-        S.WhsCode,
-        S.OnHand,
-        S.OnOrder,
-        S.IsCommited,
-        ROW_NUMBER() OVER (
-            PARTITION BY A.ParentCode
-            ORDER BY
-                CASE WHEN S.OnHand IS NULL THEN 1 ELSE 0 END,
-                S.OnHand ASC
-        ) AS rn
-    FROM AllItemsForStock AS A
-    LEFT JOIN StockRaw AS S
-      ON S.ItemCode = A.ItemCodeToCheck
-),
-Stock AS (
-    SELECT
-        SPR.ParentCode AS ItemCode,
-        SPR.WhsCode    AS warehouseCode,
-        SPR.OnHand     AS stock,
-        SPR.OnOrder    AS onOrder,
-        SPR.IsCommited AS commited
-    FROM StockPerParentRows AS SPR
-    WHERE SPR.rn = 1
-)
-SELECT
-    BP.ItemCode                                                      AS sku,
-    @cardCode                                                        AS CardCode,
-    CAST((SELECT ListNum FROM Cust) AS DECIMAL(19,4))                AS PriceList,
-    BP.Currency,
-    CAST(BP.PriceListPrice AS DECIMAL(19,4))                         AS PriceListPrice,
-    CAST(SP.OSPPPrice AS DECIMAL(19,4))                              AS OSPPPrice,
-    CAST(SP.OSPPDiscount AS DECIMAL(19,4))                           AS OSPPDiscount,
-    CAST(BD.DiscountPct AS DECIMAL(19,4))                            AS BPGroupDiscount,
-    CAST(BD.DiscountMode AS NVARCHAR(1))                             AS BPGroupDiscountType,
-    CAST(
-        CASE
-            WHEN SP.OSPPPrice IS NOT NULL AND SP.OSPPPrice > 0 THEN NULL
-            WHEN SP.OSPPDiscount IS NOT NULL THEN NULL
-            WHEN BD.DiscountPct IS NOT NULL THEN DRT.RuleType
-            WHEN PD.PromoDiscount IS NOT NULL THEN PD.PromoType
-            ELSE NULL
-        END
-        AS NVARCHAR(1)
-    )                                                                AS OedgType,
-    CAST(
-        CASE
-            WHEN SP.OSPPPrice IS NOT NULL AND SP.OSPPPrice > 0 THEN NULL
-            WHEN SP.OSPPDiscount IS NOT NULL THEN NULL
-            WHEN BD.DiscountPct IS NOT NULL THEN 1
-            WHEN PD.PromoDiscount IS NOT NULL THEN 1
-            ELSE NULL
-        END
-        AS bit
-    )                                                                AS OedgValidFor,
-    CAST(NULL AS NVARCHAR(255))                                      AS ManufacturerName,
-    CAST(NULL AS DECIMAL(19,4))                                      AS ManufacturerDiscount,
-    CAST(PD.PromoDiscount AS DECIMAL(19,4))                          AS PromoDiscount,
-    ISNULL(S.warehouseCode, '')                                      AS warehouseCode,
-    CAST(S.stock AS DECIMAL(19,4))                                   AS stock,
-    CAST(S.onOrder AS DECIMAL(19,4))                                 AS onOrder,
-    CAST(S.commited AS DECIMAL(19,4))                                AS commited,
-    CASE
-        WHEN SP.OSPPPrice IS NOT NULL AND SP.OSPPPrice > 0 THEN N'OSPP explicit price'
-        WHEN SP.OSPPDiscount IS NOT NULL THEN N'OSPP discount'
-        WHEN BD.DiscountPct IS NOT NULL THEN
-            CASE BD.DiscountMode
-                WHEN 'H' THEN N'Discount groups (highest)'
-                WHEN 'L' THEN N'Discount groups (lowest)'
-                WHEN 'A' THEN N'Discount groups (average)'
-                WHEN 'S' THEN N'Discount groups (sum)'
-                WHEN 'M' THEN N'Discount groups (mixed)'
-                ELSE N'Discount groups'
-            END
-        WHEN PD.PromoDiscount IS NOT NULL THEN N'Promo (EDG Type A)'
-        ELSE N'Base price list'
-    END                                                              AS PriceSource,
-    CAST(
-        CASE
-            WHEN SP.OSPPPrice IS NOT NULL AND SP.OSPPPrice > 0
-                THEN SP.OSPPPrice
-            WHEN SP.OSPPDiscount IS NOT NULL
-                THEN BP.PriceListPrice * (100.0 - SP.OSPPDiscount) / 100.0
-            WHEN BD.DiscountPct IS NOT NULL
-                THEN BP.PriceListPrice * (100.0 - BD.DiscountPct) / 100.0
-            WHEN PD.PromoDiscount IS NOT NULL
-                THEN BP.PriceListPrice * (100.0 - PD.PromoDiscount) / 100.0
-            ELSE BP.PriceListPrice
-        END
-        AS DECIMAL(19,4)
-    )                                                                AS FinalPrice
-FROM BasePrice AS BP
-LEFT JOIN SpecialPrice AS SP
-       ON SP.ItemCode = BP.ItemCode
-      AND (SP.ListNum IS NULL OR SP.ListNum = (SELECT ListNum FROM Cust))
-LEFT JOIN BestDiscountPerItem AS BD
-       ON BD.ItemCode = BP.ItemCode
-LEFT JOIN DiscountRuleType AS DRT
-       ON DRT.ItemCode = BP.ItemCode
-      AND DRT.rn = 1
-LEFT JOIN PromoDiscount AS PD
-       ON PD.ItemCode = BP.ItemCode
-LEFT JOIN Stock AS S
-       ON S.ItemCode = BP.ItemCode
-ORDER BY BP.ItemCode
-OPTION (RECOMPILE);
-`, skuUnion)
-
-	log.Println("====== GetProducts SQL ======")
-	log.Println(query)
-	log.Println("====== GetProducts ARGS =====")
-	for _, a := range args {
-		if na, ok := a.(sql.NamedArg); ok {
-			log.Printf("@%s = %v\n", na.Name, na.Value)
-		} else {
-			log.Printf("%T: %v\n", a, a)
-		}
-	}
-	log.Println("====== END GetProducts DUMP =====")
-
-	r.logOEDGDebug(dto)
-
-	queryStart := time.Now()
-	rows, err := r.Db.Query(query, args...)
-	if err != nil {
-		log.Printf("GetProducts: Query() error after %s: %v", time.Since(queryStart), err)
-		return nil, err
-	}
-	log.Printf("GetProducts: Query() took %s", time.Since(queryStart))
-	defer rows.Close()
-
-	scanStart := time.Now()
-	var products []Product
-	for rows.Next() {
-		var p Product
-		if err := rows.Scan(
-			&p.SKU,
-			&p.CardCode,
-			&p.PriceList,
-			&p.Currency,
-			&p.PriceListPrice,
-			&p.OSPPPrice,
-			&p.OSPPDiscount,
-			&p.BPGroupDiscount,
-			&p.BPGroupDiscountType,
-			&p.OedgType,
-			&p.OedgValidFor,
-			&p.ManufacturerName,
-			&p.ManufacturerDiscount,
-			&p.PromoDiscount,
-			&p.WarehouseCode,
-			&p.Stock,
-			&p.OnOrder,
-			&p.Commited,
-			&p.PriceSource,
-			&p.FinalPrice,
-		); err != nil {
-			log.Printf("GetProducts: scan error after %s: %v", time.Since(scanStart), err)
-			return nil, err
-		}
-		products = append(products, p)
-	}
-	log.Printf("GetProducts: scan loop took %s, rows=%d", time.Since(scanStart), len(products))
-
-	if err := rows.Err(); err != nil {
-		log.Printf("GetProducts: rows.Err(): %v", err)
-		return nil, err
-	}
-
-	log.Printf("GetProducts: DONE total=%s, rows=%d", time.Since(totalStart), len(products))
-	return products, nil
-}
-
-func (r *ProductRepository) logOEDGDebug(dto *ProductsDto) {
-	if len(dto.Skus) == 0 {
-		return
-	}
-
-	debugStart := time.Now()
-	log.Printf("GetProducts: OEDG debug start")
-
-	unionParts := make([]string, 0, len(dto.Skus))
-	args := []any{
-		sql.Named("cardCode", dto.CardCode),
-		sql.Named("asOfDate", dto.Date),
-	}
-	for i, sku := range dto.Skus {
-		name := fmt.Sprintf("sku%d", i)
-		if i == 0 {
-			unionParts = append(unionParts, fmt.Sprintf("SELECT @%s AS sku", name))
-		} else {
-			unionParts = append(unionParts, fmt.Sprintf("UNION ALL SELECT @%s", name))
-		}
-		args = append(args, sql.Named(name, sku))
-	}
-	skuUnion := strings.Join(unionParts, "\n        ")
-
-	debugQuery := fmt.Sprintf(`
-WITH SkuList AS (
-        %s
-),
-CustGroup AS (
-    SELECT TOP 1 GroupCode
-    FROM OCRD WITH (NOLOCK)
-    WHERE CardCode = @cardCode
-)
-SELECT
-    E.AbsEntry,
-    E.Type,
-    E.ObjType,
-    E.ObjCode,
-    E.ValidFor,
-    E.ValidForm,
-    E.ValidTo,
-    CASE
-        WHEN E.ValidFor = 'Y'
-         AND (E.ValidForm IS NULL OR E.ValidForm <= @asOfDate)
-         AND (E.ValidTo   IS NULL OR E.ValidTo   >= @asOfDate) THEN 1
-        ELSE 0
-    END AS oedgValidFor,
-    E1.ObjType AS LineObjType,
-    E1.ObjKey,
-    E1.Discount
-FROM OEDG AS E WITH (NOLOCK)
-JOIN EDG1 AS E1 WITH (NOLOCK)
-  ON E1.AbsEntry = E.AbsEntry
-LEFT JOIN OITM WITH (NOLOCK)
-  ON OITM.ItemCode IN (SELECT sku FROM SkuList)
-CROSS JOIN CustGroup
-WHERE
-    (
-        (E.Type = 'S' AND E.ObjCode = @cardCode)
-     OR (E.Type = 'C' AND E.ObjCode = CONVERT(NVARCHAR, CustGroup.GroupCode))
-     OR (E.ObjType = '-1' AND E.ObjCode = '0')
-    )
-  AND (
-        (E1.ObjType = '4'  AND E1.ObjKey IN (SELECT sku FROM SkuList))
-     OR (E1.ObjType = '43' AND TRY_CAST(E1.ObjKey AS INT) = OITM.FirmCode)
-     OR (E1.ObjType = '52' AND TRY_CAST(E1.ObjKey AS INT) = OITM.ItmsGrpCod)
-  )
-ORDER BY E.AbsEntry;
-`, skuUnion)
-
-	rows, err := r.Db.Query(debugQuery, args...)
-	if err != nil {
-		log.Printf("GetProducts: OEDG debug query error after %s: %v", time.Since(debugStart), err)
-		return
-	}
-	defer rows.Close()
-
-	type debugRow struct {
-		AbsEntry     int64
-		Type         sql.NullString
-		ObjType      sql.NullString
-		ObjCode      sql.NullString
-		ValidFor     sql.NullString
-		ValidForm    sql.NullTime
-		ValidTo      sql.NullTime
-		ValidCalc    sql.NullInt64
-		LineObjType  sql.NullString
-		ObjKey       sql.NullString
-		Discount     sql.NullFloat64
-	}
-
-	rowCount := 0
-	for rows.Next() {
-		var rrow debugRow
-		if err := rows.Scan(
-			&rrow.AbsEntry,
-			&rrow.Type,
-			&rrow.ObjType,
-			&rrow.ObjCode,
-			&rrow.ValidFor,
-			&rrow.ValidForm,
-			&rrow.ValidTo,
-			&rrow.ValidCalc,
-			&rrow.LineObjType,
-			&rrow.ObjKey,
-			&rrow.Discount,
-		); err != nil {
-			log.Printf("GetProducts: OEDG debug scan error: %v", err)
-			return
-		}
-		rowCount++
-		log.Printf(
-			"GetProducts: OEDG rule AbsEntry=%d Type=%s ObjType=%s ObjCode=%s ValidFor=%s ValidForm=%v ValidTo=%v ValidCalc=%v LineObjType=%s ObjKey=%s Discount=%v",
-			rrow.AbsEntry,
-			rrow.Type.String,
-			rrow.ObjType.String,
-			rrow.ObjCode.String,
-			rrow.ValidFor.String,
-			rrow.ValidForm.Time,
-			rrow.ValidTo.Time,
-			rrow.ValidCalc.Int64,
-			rrow.LineObjType.String,
-			rrow.ObjKey.String,
-			rrow.Discount.Float64,
-		)
-	}
-
-	if err := rows.Err(); err != nil {
-		log.Printf("GetProducts: OEDG debug rows.Err(): %v", err)
-		return
-	}
-
-	log.Printf("GetProducts: OEDG debug done rows=%d took=%s", rowCount, time.Since(debugStart))
-}
-
-func (r *ProductRepository) GeTreeProducts(dto *ProductSkusDto) ([]BomHeaderDTO, error) {
+func (r *ProductRepository) GeTreeProducts(ctx context.Context, dto *ProductSkusDto) ([]BomHeaderDTO, error) {
 	totalStart := time.Now()
 	log.Printf("GeTreeProducts: start, skus=%d", len(dto.Skus))
 
 	if len(dto.Skus) == 0 {
-		return nil, fmt.Errorf("sku list cannot be empty")
+		return nil, apperr.Validation("sku list cannot be empty", "skus must contain at least one sku")
 	}
 
 	unionParts := make([]string, 0, len(dto.Skus))
@@ -623,10 +80,10 @@ WHERE Code IN (SELECT sku FROM ParentSkuList)
 `, parentSkuUnion)
 
 	hQueryStart := time.Now()
-	hRows, err := r.Db.Query(headersSQL, args...)
+	hRows, err := r.Db.QueryContext(ctx, headersSQL, args...)
 	if err != nil {
 		log.Printf("GeTreeProducts: headers Query() error after %s: %v", time.Since(hQueryStart), err)
-		return nil, err
+		return nil, apperr.Upstream("product tree query failed", err)
 	}
 	log.Printf("GeTreeProducts: headers Query() took %s", time.Since(hQueryStart))
 	defer hRows.Close()
@@ -655,7 +112,7 @@ WHERE Code IN (SELECT sku FROM ParentSkuList)
 			&attachment, &uUPIIgnore, &uUPIProductionTree, &uXISComments,
 		); err != nil {
 			log.Printf("GeTreeProducts: headers scan error: %v", err)
-			return nil, err
+			return nil, apperr.Upstream("product tree query failed", err)
 		}
 
 		headersByCode[code] = &BomHeaderDTO{
@@ -698,7 +155,7 @@ WHERE Code IN (SELECT sku FROM ParentSkuList)
 
 	if err := hRows.Err(); err != nil {
 		log.Printf("GeTreeProducts: headers rows.Err(): %v", err)
-		return nil, err
+		return nil, apperr.Upstream("product tree query failed", err)
 	}
 	if len(headersByCode) == 0 {
 		log.Printf("GeTreeProducts: no headers found, total=%s", time.Since(totalStart))
@@ -721,10 +178,10 @@ ORDER BY Father, VisOrder, ChildNum, Code
 `, parentSkuUnion)
 
 	lQueryStart := time.Now()
-	lRows, err := r.Db.Query(linesSQL, args...)
+	lRows, err := r.Db.QueryContext(ctx, linesSQL, args...)
 	if err != nil {
 		log.Printf("GeTreeProducts: lines Query() error after %s: %v", time.Since(lQueryStart), err)
-		return nil, err
+		return nil, apperr.Upstream("product tree query failed", err)
 	}
 	log.Printf("GeTreeProducts: lines Query() took %s", time.Since(lQueryStart))
 	defer lRows.Close()
@@ -751,7 +208,7 @@ ORDER BY Father, VisOrder, ChildNum, Code
 			&uUPIBaseEl, &uIsVisibleOnWebshop, &uInvCalc,
 		); err != nil {
 			log.Printf("GeTreeProducts: lines scan error: %v", err)
-			return nil, err
+			return nil, apperr.Upstream("product tree query failed", err)
 		}
 		lineCount++
 
@@ -796,7 +253,7 @@ ORDER BY Father, VisOrder, ChildNum, Code
 
 	if err := lRows.Err(); err != nil {
 		log.Printf("GeTreeProducts: lines rows.Err(): %v", err)
-		return nil, err
+		return nil, apperr.Upstream("product tree query failed", err)
 	}
 
 	result := make([]BomHeaderDTO, 0, len(headersByCode))
@@ -819,15 +276,15 @@ ORDER BY Father, VisOrder, ChildNum, Code
 	return result, nil
 }
 
-func (r *ProductRepository) GetProductStocksData(dto *ProductSkusStockDto) ([]ProductStock, error) {
+func (r *ProductRepository) GetProductStocksData(ctx context.Context, dto *ProductSkusStockDto) ([]ProductStock, error) {
 	totalStart := time.Now()
 	log.Printf("GetProductStocksData: start, skus=%d, warehouse=%s", len(dto.Skus), dto.Warehouse)
 
 	if len(dto.Skus) == 0 {
-		return nil, fmt.Errorf("sku list cannot be empty")
+		return nil, apperr.Validation("sku list cannot be empty", "skus must contain at least one sku")
 	}
 	if dto.Warehouse == "" {
-		return nil, fmt.Errorf("warehouse is required")
+		return nil, apperr.Invalid("warehouse is required")
 	}
 
 	unionParts := make([]string, 0, len(dto.Skus))
@@ -923,10 +380,10 @@ ORDER BY P.sku;
 `, parentSkuUnion)
 
 	qStart := time.Now()
-	rows, err := r.Db.Query(query, args...)
+	rows, err := r.Db.QueryContext(ctx, query, args...)
 	if err != nil {
 		log.Printf("GetProductStocksData: Query() error after %s: %v", time.Since(qStart), err)
-		return nil, err
+		return nil, apperr.Upstream("product stock query failed", err)
 	}
 	log.Printf("GetProductStocksData: Query() took %s", time.Since(qStart))
 	defer rows.Close()
@@ -943,7 +400,7 @@ ORDER BY P.sku;
 			&ps.Commited,
 		); err != nil {
 			log.Printf("GetProductStocksData: scan error: %v", err)
-			return nil, err
+			return nil, apperr.Upstream("product stock query failed", err)
 		}
 		result = append(result, ps)
 	}
@@ -951,7 +408,7 @@ ORDER BY P.sku;
 
 	if err := rows.Err(); err != nil {
 		log.Printf("GetProductStocksData: rows.Err(): %v", err)
-		return nil, err
+		return nil, apperr.Upstream("product stock query failed", err)
 	}
 
 	log.Printf("GetProductStocksData: DONE total=%s, rows=%d", time.Since(totalStart), len(result))
@@ -0,0 +1,173 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"sql-service/pkg/apperr"
+)
+
+// Sources an input code can resolve through, in order of precedence.
+const (
+	skuSourceItemCode = iota
+	skuSourceCodeBars
+	skuSourceBarcode
+	skuSourceCatalogNumber
+)
+
+// skuMatch is an item reached by an input code through one of the skuSource*.
+type skuMatch struct {
+	Input    string
+	ItemCode string
+	Source   int
+}
+
+// ResolveItemCodes looks the inputs up as ItemCodes, OITM.CodeBars, OBCD
+// barcodes and, when cardCode is set, as the customer's OSCN catalog numbers.
+// An input may match several items, the caller picks one.
+func (r *ProductRepository) ResolveItemCodes(ctx context.Context, inputs []string, cardCode string) ([]skuMatch, error) {
+	if len(inputs) == 0 {
+		return nil, nil
+	}
+
+	list, args := namedList("code", inputs)
+	query := fmt.Sprintf(`
+SELECT T0.ItemCode AS Input, T0.ItemCode, %[2]d AS Source
+FROM OITM AS T0 WITH (NOLOCK)
+WHERE T0.ItemCode IN (%[1]s)
+UNION ALL
+SELECT T0.CodeBars, T0.ItemCode, %[3]d
+FROM OITM AS T0 WITH (NOLOCK)
+WHERE T0.CodeBars IN (%[1]s)
+UNION ALL
+SELECT B.BcdCode, B.ItemCode, %[4]d
+FROM OBCD AS B WITH (NOLOCK)
+WHERE B.BcdCode IN (%[1]s)`, list, skuSourceItemCode, skuSourceCodeBars, skuSourceBarcode)
+	if cardCode != "" {
+		query += fmt.Sprintf(`
+UNION ALL
+SELECT S.Substitute, S.ItemCode, %[2]d
+FROM OSCN AS S WITH (NOLOCK)
+WHERE S.CardCode = @cardCode
+  AND S.Substitute IN (%[1]s)`, list, skuSourceCatalogNumber)
+		args = append(args, sql.Named("cardCode", cardCode))
+	}
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("item code resolution failed", err)
+	}
+	defer rows.Close()
+
+	matches := make([]skuMatch, 0, len(inputs))
+	for rows.Next() {
+		var match skuMatch
+		if err := rows.Scan(&match.Input, &match.ItemCode, &match.Source); err != nil {
+			return nil, apperr.Upstream("item code resolution failed", err)
+		}
+		matches = append(matches, match)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("item code resolution failed", err)
+	}
+	return matches, nil
+}
+
+// skuResolution maps the codes of a request to ItemCodes. ItemCodes keeps the
+// order of the first input of every item, an input that resolves to nothing
+// is kept as is so that it is reported the way an unknown sku always was.
+type skuResolution struct {
+	ItemCodes []string
+	inputs    map[string][]string
+}
+
+// resolveSkus resolves the inputs, preferring an exact ItemCode over OITM
+// barcodes, OBCD barcodes and catalog numbers, and the lowest ItemCode when
+// one source matches several items.
+func (service *ProductService) resolveSkus(ctx context.Context, inputs []string, cardCode string) (*skuResolution, error) {
+	matches, err := runSkuChunks(ctx, inputs, func(ctx context.Context, chunk []string) ([]skuMatch, error) {
+		return service.productRepository.ResolveItemCodes(ctx, chunk, cardCode)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	best := make(map[string]skuMatch, len(matches))
+	for _, match := range matches {
+		key := skuKey(match.Input)
+		current, ok := best[key]
+		if !ok || match.Source < current.Source || (match.Source == current.Source && match.ItemCode < current.ItemCode) {
+			best[key] = match
+		}
+	}
+
+	resolution := &skuResolution{
+		ItemCodes: make([]string, 0, len(inputs)),
+		inputs:    make(map[string][]string, len(inputs)),
+	}
+	for _, input := range inputs {
+		itemCode := input
+		if match, ok := best[skuKey(input)]; ok {
+			itemCode = match.ItemCode
+		}
+		key := skuKey(itemCode)
+		if _, ok := resolution.inputs[key]; !ok {
+			resolution.ItemCodes = append(resolution.ItemCodes, itemCode)
+		}
+		resolution.inputs[key] = append(resolution.inputs[key], input)
+	}
+	return resolution, nil
+}
+
+// quantities rekeys per sku quantities by ItemCode. A quantity given for the
+// ItemCode itself wins over one given for a barcode of it.
+func (s *skuResolution) quantities(quantities map[string]float64) map[string]float64 {
+	if len(quantities) == 0 {
+		return quantities
+	}
+
+	byInput := make(map[string]float64, len(quantities))
+	for sku, quantity := range quantities {
+		byInput[skuKey(sku)] = quantity
+	}
+
+	out := make(map[string]float64, len(quantities))
+	for _, itemCode := range s.ItemCodes {
+		if quantity, ok := byInput[skuKey(itemCode)]; ok {
+			out[itemCode] = quantity
+			continue
+		}
+		for _, input := range s.inputs[skuKey(itemCode)] {
+			if quantity, ok := byInput[skuKey(input)]; ok {
+				out[itemCode] = quantity
+				break
+			}
+		}
+	}
+	return out
+}
+
+// echoInputs repeats every row once per input that resolved to its item, with
+// the input set on the copy.
+func echoInputs[T any](rows []T, s *skuResolution, itemCode func(*T) string, setInput func(*T, string)) []T {
+	out := make([]T, 0, len(rows))
+	for _, row := range rows {
+		inputs := s.inputs[skuKey(itemCode(&row))]
+		if len(inputs) == 0 {
+			out = append(out, row)
+			continue
+		}
+		for _, input := range inputs {
+			copied := row
+			setInput(&copied, input)
+			out = append(out, copied)
+		}
+	}
+	return out
+}
+
+func skuKey(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
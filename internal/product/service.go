@@ -1,8 +1,15 @@
 package product
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
 	"log"
+	"strings"
 	"time"
+
+	"sql-service/internal/pricing"
+	"sql-service/pkg/apperr"
 )
 
 type ProductService struct {
@@ -15,44 +22,320 @@ func NewProductService(repo *ProductRepository) *ProductService {
 	}
 }
 
-func (service *ProductService) ProductServiceHandler(dto *ProductsDto) []Product {
+func (service *ProductService) ProductServiceHandler(ctx context.Context, dto *ProductsDto) ([]Product, error) {
 	start := time.Now()
 	log.Printf("ProductServiceHandler: start, skus=%d, cardCode=%s", len(dto.Skus), dto.CardCode)
 
-	result, err := service.productRepository.GetProducts(dto)
+	skus := dedupeSkus(dto.Skus)
+	if len(skus) == 0 {
+		return nil, apperr.Validation("sku list cannot be empty", "skus must contain at least one sku")
+	}
+	date, err := parsePricingDate(dto.Date)
+	if err != nil {
+		return nil, apperr.Validation("invalid date", err.Error())
+	}
+	for sku, quantity := range dto.Quantities {
+		if quantity <= 0 {
+			return nil, apperr.Validation("quantities must be positive", "invalid quantity for sku "+sku)
+		}
+	}
+
+	customers, err := service.productRepository.GetPricingCustomers(ctx, []string{dto.CardCode})
+	if err != nil {
+		return nil, err
+	}
+	if len(customers) == 0 {
+		log.Printf("ProductServiceHandler: customer %s has no price list, elapsed=%s", dto.CardCode, time.Since(start))
+		return []Product{}, nil
+	}
+
+	resolution, err := service.resolveSkus(ctx, skus, dto.CardCode)
 	if err != nil {
 		log.Printf("ProductServiceHandler: error after %s: %v", time.Since(start), err)
-		return nil
+		return nil, err
 	}
+	priced := *dto
+	priced.Quantities = resolution.quantities(dto.Quantities)
+
+	result, err := runSkuChunks(ctx, resolution.ItemCodes, func(ctx context.Context, chunk []string) ([]Product, error) {
+		return service.priceProducts(ctx, &priced, customers[0], date, chunk)
+	})
+	if err != nil {
+		log.Printf("ProductServiceHandler: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+	if result == nil {
+		result = []Product{}
+	}
+	result = inSkuOrder(result, resolution.ItemCodes, func(p *Product) string { return p.SKU })
+
+	if target := strings.ToUpper(strings.TrimSpace(dto.TargetCurrency)); target != "" && len(result) > 0 {
+		converter, err := service.newCurrencyConverter(ctx, result, target, date)
+		if err != nil {
+			log.Printf("ProductServiceHandler: currency conversion failed after %s: %v", time.Since(start), err)
+			return nil, err
+		}
+		converter.convertProducts(result, target)
+	}
+	result = echoInputs(result, resolution, func(p *Product) string { return p.SKU }, func(p *Product, input string) { p.Input = input })
 
 	log.Printf("ProductServiceHandler: success, rows=%d, elapsed=%s", len(result), time.Since(start))
-	return result
+	return result, nil
+}
+
+// priceProducts resolves the prices of one chunk of skus and adds the stock of
+// the requested warehouse.
+func (service *ProductService) priceProducts(ctx context.Context, dto *ProductsDto, customer pricing.Customer, date time.Time, skus []string) ([]Product, error) {
+	data, err := service.productRepository.GetPricingData(ctx, []pricing.Customer{customer}, skus)
+	if err != nil {
+		return nil, err
+	}
+	prices := pricing.Resolve(customer, data, pricing.Options{
+		Date:       date,
+		Quantities: dto.Quantities,
+		Explain:    dto.Explain,
+	})
+	if len(prices) == 0 {
+		return []Product{}, nil
+	}
+
+	stocks := map[string]ProductStock{}
+	if dto.Warehouse != "" {
+		itemCodes := make([]string, len(prices))
+		for i, price := range prices {
+			itemCodes[i] = price.ItemCode
+		}
+		rows, err := service.productRepository.GetProductStocksData(ctx, &ProductSkusStockDto{Skus: itemCodes, Warehouse: dto.Warehouse})
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			stocks[row.SKU] = row
+		}
+	}
+
+	products := make([]Product, 0, len(prices))
+	for _, price := range prices {
+		products = append(products, newProduct(dto.CardCode, price, stocks[price.ItemCode]))
+	}
+	return products, nil
+}
+
+func newProduct(cardCode string, price pricing.Result, stock ProductStock) Product {
+	product := Product{
+		SKU:                 price.ItemCode,
+		CardCode:            cardCode,
+		PriceList:           myNullFloat(float64(price.PriceList)),
+		Currency:            myNullString(price.Currency),
+		PriceListPrice:      myNullFloatPtr(price.PriceListPrice),
+		OSPPPrice:           myNullFloatPtr(price.OSPPPrice),
+		OSPPDiscount:        myNullFloatPtr(price.OSPPDiscount),
+		BPGroupDiscount:     myNullFloatPtr(price.GroupDiscount),
+		BPGroupDiscountType: myNullString(price.DiscountMode),
+		OedgType:            myNullString(price.OedgType),
+		PromoDiscount:       myNullFloatPtr(price.PromoDiscount),
+		PriceSource:         price.Source,
+		FinalPrice:          price.FinalPrice,
+		Quantity:            price.Quantity,
+		PriceTier:           price.PriceTier,
+		NextBreak:           price.NextBreak,
+		Explain:             price.Explain,
+	}
+	if price.OedgValidFor != nil {
+		product.OedgValidFor = MyNullBool{sql.NullBool{Bool: *price.OedgValidFor, Valid: true}}
+	}
+
+	// a sku without an OITW row in the warehouse reports no warehouse
+	if stock.Stock.Valid {
+		product.WarehouseCode = stock.WarehouseCode
+		product.Stock = stock.Stock
+		product.OnOrder = stock.OnOrder
+		product.Commited = stock.Commited
+	}
+	return product
+}
+
+// parsePricingDate accepts a plain date or an RFC 3339 timestamp.
+func parsePricingDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func myNullFloat(value float64) MyNullFloat64 {
+	return MyNullFloat64{sql.NullFloat64{Float64: value, Valid: true}}
+}
+
+func myNullFloatPtr(value *float64) MyNullFloat64 {
+	if value == nil {
+		return MyNullFloat64{}
+	}
+	return myNullFloat(*value)
+}
+
+func myNullString(value *string) MyNullString {
+	if value == nil {
+		return MyNullString{}
+	}
+	return MyNullString{sql.NullString{String: *value, Valid: true}}
 }
 
-func (service *ProductService) ProductTreeHandler(dto *ProductSkusDto) []BomHeaderDTO {
+func (service *ProductService) ProductTreeHandler(ctx context.Context, dto *ProductSkusDto) ([]BomHeaderDTO, error) {
 	start := time.Now()
 	log.Printf("ProductTreeHandler: start, skus=%d", len(dto.Skus))
 
-	result, err := service.productRepository.GeTreeProducts(dto)
+	resolution, err := service.resolveSkus(ctx, dedupeSkus(dto.Skus), dto.CardCode)
 	if err != nil {
 		log.Printf("ProductTreeHandler: error after %s: %v", time.Since(start), err)
-		return nil
+		return nil, err
 	}
+	result, err := runSkuChunks(ctx, resolution.ItemCodes, func(ctx context.Context, chunk []string) ([]BomHeaderDTO, error) {
+		return service.productRepository.GeTreeProducts(ctx, &ProductSkusDto{Skus: chunk})
+	})
+	if err != nil {
+		log.Printf("ProductTreeHandler: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+	result = inSkuOrder(result, resolution.ItemCodes, func(h *BomHeaderDTO) string { return h.Code })
+	result = echoInputs(result, resolution, func(h *BomHeaderDTO) string { return h.Code }, func(h *BomHeaderDTO, input string) { h.Input = input })
 
 	log.Printf("ProductTreeHandler: success, headers=%d, elapsed=%s", len(result), time.Since(start))
-	return result
+	return result, nil
 }
 
-func (service *ProductService) ProductStocks(dto *ProductSkusStockDto) []ProductStock {
+func (service *ProductService) ProductStocks(ctx context.Context, dto *ProductSkusStockDto) ([]ProductStock, error) {
 	start := time.Now()
 	log.Printf("ProductStocks: start, skus=%d, warehouse=%s", len(dto.Skus), dto.Warehouse)
 
-	result, err := service.productRepository.GetProductStocksData(dto)
+	resolution, err := service.resolveSkus(ctx, dedupeSkus(dto.Skus), dto.CardCode)
 	if err != nil {
 		log.Printf("ProductStocks: error after %s: %v", time.Since(start), err)
-		return nil
+		return nil, err
 	}
+	result, err := runSkuChunks(ctx, resolution.ItemCodes, func(ctx context.Context, chunk []string) ([]ProductStock, error) {
+		chunkDto := *dto
+		chunkDto.Skus = chunk
+		return service.productRepository.GetProductStocksData(ctx, &chunkDto)
+	})
+	if err != nil {
+		log.Printf("ProductStocks: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+	result = inSkuOrder(result, resolution.ItemCodes, func(s *ProductStock) string { return s.SKU })
+	result = echoInputs(result, resolution, func(s *ProductStock) string { return s.SKU }, func(s *ProductStock, input string) { s.Input = input })
 
 	log.Printf("ProductStocks: success, rows=%d, elapsed=%s", len(result), time.Since(start))
-	return result
+	return result, nil
+}
+
+// priceMatrixMaxCustomers keeps the batched pricing queries, which bind every
+// customer and customer group next to a sku chunk, under the parameter cap.
+const priceMatrixMaxCustomers = 200
+
+// PriceMatrix prices the skus for several customers at once. Every sku chunk
+// loads the rows of all customers in one batch and resolves them in memory.
+func (service *ProductService) PriceMatrix(ctx context.Context, dto *PriceMatrixDto) (*PriceMatrix, error) {
+	start := time.Now()
+	log.Printf("PriceMatrix: start, skus=%d, cardCodes=%d", len(dto.Skus), len(dto.CardCodes))
+
+	skus := dedupeSkus(dto.Skus)
+	if len(skus) == 0 {
+		return nil, apperr.Validation("sku list cannot be empty", "skus must contain at least one sku")
+	}
+	cardCodes := dedupeSkus(dto.CardCodes)
+	if (len(cardCodes) == 0) == (dto.CustomerGroup == nil) {
+		return nil, apperr.Invalid("exactly one of cardCodes or customerGroup is required")
+	}
+	if len(cardCodes) > priceMatrixMaxCustomers {
+		return nil, apperr.Validation("too many customers", fmt.Sprintf("at most %d customers are supported", priceMatrixMaxCustomers))
+	}
+	date, err := parsePricingDate(dto.Date)
+	if err != nil {
+		return nil, apperr.Validation("invalid date", err.Error())
+	}
+	for sku, quantity := range dto.Quantities {
+		if quantity <= 0 {
+			return nil, apperr.Validation("quantities must be positive", "invalid quantity for sku "+sku)
+		}
+	}
+
+	var customers []pricing.Customer
+	if dto.CustomerGroup != nil {
+		customers, err = service.productRepository.GetPricingCustomersByGroup(ctx, *dto.CustomerGroup)
+	} else {
+		customers, err = service.productRepository.GetPricingCustomers(ctx, cardCodes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(customers) > priceMatrixMaxCustomers {
+		return nil, apperr.Validation("too many customers", fmt.Sprintf("customer group has %d customers, at most %d are supported", len(customers), priceMatrixMaxCustomers))
+	}
+
+	matrix := &PriceMatrix{
+		Date:             date.Format("2006-01-02"),
+		Customers:        make([]PriceMatrixCustomer, 0, len(customers)),
+		MissingCustomers: []string{},
+	}
+	found := make(map[string]bool, len(customers))
+	for _, customer := range customers {
+		found[strings.ToUpper(customer.CardCode)] = true
+		matrix.Customers = append(matrix.Customers, PriceMatrixCustomer{
+			CardCode:     customer.CardCode,
+			PriceList:    customer.ListNum,
+			GroupCode:    customer.GroupCode,
+			DiscountMode: pricing.DiscountMode(customer),
+		})
+	}
+	for _, cardCode := range cardCodes {
+		if !found[strings.ToUpper(cardCode)] {
+			matrix.MissingCustomers = append(matrix.MissingCustomers, cardCode)
+		}
+	}
+	if len(customers) == 0 {
+		matrix.Rows = []PriceMatrixRow{}
+		return matrix, nil
+	}
+
+	opts := pricing.Options{Date: date, Quantities: dto.Quantities}
+	rows, err := runSkuChunks(ctx, skus, func(ctx context.Context, chunk []string) ([]PriceMatrixRow, error) {
+		data, err := service.productRepository.GetPricingData(ctx, customers, chunk)
+		if err != nil {
+			return nil, err
+		}
+
+		bySku := make(map[string]map[string]PriceMatrixCell, len(chunk))
+		for _, customer := range customers {
+			for _, price := range pricing.Resolve(customer, data, opts) {
+				key := strings.ToUpper(price.ItemCode)
+				if bySku[key] == nil {
+					bySku[key] = map[string]PriceMatrixCell{}
+				}
+				bySku[key][customer.CardCode] = PriceMatrixCell{
+					Currency:    price.Currency,
+					FinalPrice:  price.FinalPrice,
+					PriceSource: price.Source,
+				}
+			}
+		}
+
+		out := make([]PriceMatrixRow, 0, len(chunk))
+		for _, sku := range chunk {
+			prices := bySku[strings.ToUpper(sku)]
+			if prices == nil {
+				prices = map[string]PriceMatrixCell{}
+			}
+			out = append(out, PriceMatrixRow{SKU: sku, Prices: prices})
+		}
+		return out, nil
+	})
+	if err != nil {
+		log.Printf("PriceMatrix: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+	matrix.Rows = rows
+
+	log.Printf("PriceMatrix: success, customers=%d, rows=%d, elapsed=%s", len(customers), len(rows), time.Since(start))
+	return matrix, nil
 }
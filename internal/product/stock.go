@@ -0,0 +1,419 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"sql-service/pkg/apperr"
+)
+
+type warehouseStockRow struct {
+	ItemCode      string
+	WarehouseCode string
+	OnHand        float64
+	Commited      float64
+	OnOrder       float64
+}
+
+// stockMovement is the open quantity of a sales order line (negative) or a
+// purchase order line (positive) due on Date.
+type stockMovement struct {
+	ItemCode      string
+	WarehouseCode string
+	Date          time.Time
+	Quantity      float64
+}
+
+// kitChildrenSubquery selects the children of the sales BOMs in the sku list
+// bound to %[1]s; their stock stands in for the kit's own.
+const kitChildrenSubquery = `SELECT L.Code FROM ITT1 AS L WITH (NOLOCK)
+        INNER JOIN OITT AS H WITH (NOLOCK) ON H.Code = L.Father AND H.TreeType = 'S'
+        WHERE L.Father IN (%[1]s)`
+
+// GetKitChildren returns the children of the skus that are sales BOMs, keyed
+// by the upper cased parent code.
+func (r *ProductRepository) GetKitChildren(ctx context.Context, skus []string) (map[string][]string, error) {
+	skuList, args := namedList("sku", skus)
+	query := fmt.Sprintf(`
+SELECT H.Code, L.Code
+FROM OITT AS H WITH (NOLOCK)
+INNER JOIN ITT1 AS L WITH (NOLOCK)
+    ON L.Father = H.Code
+WHERE H.TreeType = 'S'
+  AND H.Code IN (%s)
+ORDER BY H.Code, L.ChildNum`, skuList)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("product stock query failed", err)
+	}
+	defer rows.Close()
+
+	children := map[string][]string{}
+	for rows.Next() {
+		var parent, child string
+		if err := rows.Scan(&parent, &child); err != nil {
+			return nil, apperr.Upstream("product stock query failed", err)
+		}
+		key := strings.ToUpper(parent)
+		children[key] = append(children[key], child)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("product stock query failed", err)
+	}
+	return children, nil
+}
+
+// GetWarehouseStock returns the OITW rows of the skus and of the children of
+// the kits among them, in the given warehouses or in all when none are given.
+func (r *ProductRepository) GetWarehouseStock(ctx context.Context, skus, warehouses []string) ([]warehouseStockRow, error) {
+	skuList, args := namedList("sku", skus)
+	whsFilter, whsArgs := warehouseFilter("W.WhsCode", warehouses)
+	args = append(args, whsArgs...)
+
+	query := fmt.Sprintf(`
+SELECT W.ItemCode, W.WhsCode, W.OnHand, W.IsCommited, W.OnOrder
+FROM OITW AS W WITH (NOLOCK)
+WHERE (W.ItemCode IN (%[1]s) OR W.ItemCode IN (`+kitChildrenSubquery+`))
+  AND %[2]s
+ORDER BY W.ItemCode, W.WhsCode`, skuList, whsFilter)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("product stock query failed", err)
+	}
+	defer rows.Close()
+
+	result := make([]warehouseStockRow, 0)
+	for rows.Next() {
+		var (
+			row                       warehouseStockRow
+			onHand, commited, onOrder sql.NullFloat64
+		)
+		if err := rows.Scan(&row.ItemCode, &row.WarehouseCode, &onHand, &commited, &onOrder); err != nil {
+			return nil, apperr.Upstream("product stock query failed", err)
+		}
+		row.OnHand, row.Commited, row.OnOrder = onHand.Float64, commited.Float64, onOrder.Float64
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("product stock query failed", err)
+	}
+	return result, nil
+}
+
+// GetOpenStockMovements returns the open RDR1 and POR1 lines of the same items
+// as GetWarehouseStock, by due date.
+func (r *ProductRepository) GetOpenStockMovements(ctx context.Context, skus, warehouses []string) ([]stockMovement, error) {
+	skuList, args := namedList("sku", skus)
+	whsFilter, whsArgs := warehouseFilter("L.WhsCode", warehouses)
+	args = append(args, whsArgs...)
+	items := fmt.Sprintf("(L.ItemCode IN (%[1]s) OR L.ItemCode IN ("+kitChildrenSubquery+"))", skuList)
+
+	query := fmt.Sprintf(`
+SELECT L.ItemCode, L.WhsCode, L.ShipDate, -L.OpenQty
+FROM RDR1 AS L WITH (NOLOCK)
+INNER JOIN ORDR AS O WITH (NOLOCK) ON O.DocEntry = L.DocEntry
+WHERE L.LineStatus = 'O'
+  AND O.CANCELED = 'N'
+  AND %[1]s
+  AND %[2]s
+UNION ALL
+SELECT L.ItemCode, L.WhsCode, L.ShipDate, L.OpenQty
+FROM POR1 AS L WITH (NOLOCK)
+INNER JOIN OPOR AS O WITH (NOLOCK) ON O.DocEntry = L.DocEntry
+WHERE L.LineStatus = 'O'
+  AND O.CANCELED = 'N'
+  AND %[1]s
+  AND %[2]s
+ORDER BY 3`, items, whsFilter)
+
+	rows, err := r.Db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, apperr.Upstream("open orders query failed", err)
+	}
+	defer rows.Close()
+
+	result := make([]stockMovement, 0)
+	for rows.Next() {
+		var (
+			row      stockMovement
+			whsCode  sql.NullString
+			shipDate sql.NullTime
+			quantity sql.NullFloat64
+		)
+		if err := rows.Scan(&row.ItemCode, &whsCode, &shipDate, &quantity); err != nil {
+			return nil, apperr.Upstream("open orders query failed", err)
+		}
+		row.WarehouseCode = whsCode.String
+		row.Date = shipDate.Time
+		row.Quantity = quantity.Float64
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("open orders query failed", err)
+	}
+	return result, nil
+}
+
+func warehouseFilter(column string, warehouses []string) (string, []any) {
+	if len(warehouses) == 0 {
+		return "1 = 1", nil
+	}
+	list, args := namedList("whs", warehouses)
+	return fmt.Sprintf("%s IN (%s)", column, list), args
+}
+
+// ProductWarehouseStocks returns the stock of every sku per warehouse, kits
+// taking the stock of their lowest stocked child like the single warehouse
+// mode does. With AllWarehouses, warehouses without any quantity are left out;
+// otherwise every requested warehouse is listed, with zeros where the item has
+// no stock row.
+func (service *ProductService) ProductWarehouseStocks(ctx context.Context, dto *ProductSkusStockDto) ([]ProductWarehouseStock, error) {
+	start := time.Now()
+	log.Printf("ProductWarehouseStocks: start, skus=%d, warehouses=%d, all=%t, atp=%t",
+		len(dto.Skus), len(dto.Warehouses), dto.AllWarehouses, dto.Atp)
+
+	skus := dedupeSkus(dto.Skus)
+	if len(skus) == 0 {
+		return nil, apperr.Validation("sku list cannot be empty", "skus must contain at least one sku")
+	}
+	warehouses := dedupeSkus(dto.Warehouses)
+	if dto.AllWarehouses {
+		warehouses = nil
+	}
+
+	resolution, err := service.resolveSkus(ctx, skus, dto.CardCode)
+	if err != nil {
+		log.Printf("ProductWarehouseStocks: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+
+	result, err := runSkuChunks(ctx, resolution.ItemCodes, func(ctx context.Context, chunk []string) ([]ProductWarehouseStock, error) {
+		return service.warehouseStocks(ctx, chunk, warehouses, dto.AllWarehouses, dto.Atp)
+	})
+	if err != nil {
+		log.Printf("ProductWarehouseStocks: error after %s: %v", time.Since(start), err)
+		return nil, err
+	}
+	result = inSkuOrder(result, resolution.ItemCodes, func(s *ProductWarehouseStock) string { return s.SKU })
+	result = echoInputs(result, resolution, func(s *ProductWarehouseStock) string { return s.SKU }, func(s *ProductWarehouseStock, input string) { s.Input = input })
+
+	log.Printf("ProductWarehouseStocks: success, rows=%d, elapsed=%s", len(result), time.Since(start))
+	return result, nil
+}
+
+func (service *ProductService) warehouseStocks(ctx context.Context, skus, warehouses []string, all, atp bool) ([]ProductWarehouseStock, error) {
+	children, err := service.productRepository.GetKitChildren(ctx, skus)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := service.productRepository.GetWarehouseStock(ctx, skus, warehouses)
+	if err != nil {
+		return nil, err
+	}
+	var movements []stockMovement
+	if atp {
+		if movements, err = service.productRepository.GetOpenStockMovements(ctx, skus, warehouses); err != nil {
+			return nil, err
+		}
+	}
+
+	// item -> warehouse -> stock
+	stock := map[string]map[string]WarehouseStock{}
+	for _, row := range rows {
+		item := strings.ToUpper(row.ItemCode)
+		if stock[item] == nil {
+			stock[item] = map[string]WarehouseStock{}
+		}
+		stock[item][row.WarehouseCode] = WarehouseStock{
+			WarehouseCode: row.WarehouseCode,
+			OnHand:        row.OnHand,
+			Commited:      row.Commited,
+			OnOrder:       row.OnOrder,
+			Available:     row.OnHand - row.Commited + row.OnOrder,
+		}
+	}
+	// item -> warehouse -> movements
+	moves := map[string]map[string][]stockMovement{}
+	for _, move := range movements {
+		item := strings.ToUpper(move.ItemCode)
+		if moves[item] == nil {
+			moves[item] = map[string][]stockMovement{}
+		}
+		moves[item][move.WarehouseCode] = append(moves[item][move.WarehouseCode], move)
+	}
+
+	result := make([]ProductWarehouseStock, 0, len(skus))
+	for _, sku := range skus {
+		key := strings.ToUpper(sku)
+		kit := children[key]
+		entry := ProductWarehouseStock{SKU: sku, IsKit: len(kit) > 0, Warehouses: []WarehouseStock{}}
+
+		var perWarehouse map[string]WarehouseStock
+		if entry.IsKit {
+			perWarehouse = kitStock(kit, stock, moves, atp)
+		} else {
+			perWarehouse = map[string]WarehouseStock{}
+			for whs, ws := range stock[key] {
+				if atp {
+					ws.Atp = projectAtp(ws.OnHand, moves[key][whs])
+				}
+				perWarehouse[whs] = ws
+			}
+		}
+
+		listed := make(map[string]bool, len(perWarehouse))
+		for _, ws := range perWarehouse {
+			if all && ws.OnHand == 0 && ws.Commited == 0 && ws.OnOrder == 0 && len(ws.Atp) == 0 {
+				continue
+			}
+			listed[strings.ToUpper(ws.WarehouseCode)] = true
+			entry.Warehouses = append(entry.Warehouses, ws)
+		}
+		// a requested warehouse without a row has no stock, it was still queried
+		for _, whs := range warehouses {
+			if !listed[strings.ToUpper(whs)] {
+				listed[strings.ToUpper(whs)] = true
+				entry.Warehouses = append(entry.Warehouses, WarehouseStock{WarehouseCode: whs})
+			}
+		}
+		sort.Slice(entry.Warehouses, func(i, j int) bool {
+			return entry.Warehouses[i].WarehouseCode < entry.Warehouses[j].WarehouseCode
+		})
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// kitStock takes, per warehouse, the lowest on hand, committed, on order and
+// available quantity of the children, each on its own, and for ATP the lowest
+// projected quantity of any child per date. A child without a row in a
+// warehouse another child is stocked in counts as zero there.
+func kitStock(kit []string, stock map[string]map[string]WarehouseStock, moves map[string]map[string][]stockMovement, atp bool) map[string]WarehouseStock {
+	warehouses := map[string]bool{}
+	for _, child := range kit {
+		childKey := strings.ToUpper(child)
+		for whs := range stock[childKey] {
+			warehouses[whs] = true
+		}
+		if atp {
+			for whs := range moves[childKey] {
+				warehouses[whs] = true
+			}
+		}
+	}
+
+	perWarehouse := make(map[string]WarehouseStock, len(warehouses))
+	for whs := range warehouses {
+		kitWs := WarehouseStock{WarehouseCode: whs}
+		projections := make([]atpProjection, 0, len(kit))
+		for i, child := range kit {
+			childKey := strings.ToUpper(child)
+			ws := stock[childKey][whs]
+			if i == 0 {
+				kitWs.OnHand, kitWs.Commited, kitWs.OnOrder, kitWs.Available = ws.OnHand, ws.Commited, ws.OnOrder, ws.Available
+			} else {
+				kitWs.OnHand = min(kitWs.OnHand, ws.OnHand)
+				kitWs.Commited = min(kitWs.Commited, ws.Commited)
+				kitWs.OnOrder = min(kitWs.OnOrder, ws.OnOrder)
+				kitWs.Available = min(kitWs.Available, ws.Available)
+			}
+			if atp {
+				projections = append(projections, atpProjection{
+					OnHand: ws.OnHand,
+					Points: projectAtp(ws.OnHand, moves[childKey][whs]),
+				})
+			}
+		}
+		if atp {
+			kitWs.Atp = minAtp(projections)
+		}
+		perWarehouse[whs] = kitWs
+	}
+	return perWarehouse
+}
+
+// projectAtp runs the on hand quantity through the open order lines by due date.
+func projectAtp(onHand float64, movements []stockMovement) []AtpPoint {
+	if len(movements) == 0 {
+		return nil
+	}
+	sorted := append([]stockMovement(nil), movements...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	points := make([]AtpPoint, 0)
+	available := onHand
+	for _, move := range sorted {
+		date := move.Date.Format("2006-01-02")
+		if len(points) == 0 || points[len(points)-1].Date != date {
+			points = append(points, AtpPoint{Date: date})
+		}
+		point := &points[len(points)-1]
+		if move.Quantity >= 0 {
+			point.Receipts += move.Quantity
+		} else {
+			point.Issues -= move.Quantity
+		}
+		available += move.Quantity
+		point.Available = available
+	}
+	return points
+}
+
+type atpProjection struct {
+	OnHand float64
+	Points []AtpPoint
+}
+
+// minAtp merges the projections of a kit's children, keeping per date the
+// lowest quantity any child is projected to have by then.
+func minAtp(projections []atpProjection) []AtpPoint {
+	dates := map[string]bool{}
+	for _, projection := range projections {
+		for _, point := range projection.Points {
+			dates[point.Date] = true
+		}
+	}
+	if len(dates) == 0 {
+		return nil
+	}
+	ordered := make([]string, 0, len(dates))
+	for date := range dates {
+		ordered = append(ordered, date)
+	}
+	sort.Strings(ordered)
+
+	merged := make([]AtpPoint, 0, len(ordered))
+	for _, date := range ordered {
+		var point AtpPoint
+		for i, projection := range projections {
+			candidate := projection.at(date)
+			if i == 0 || candidate.Available < point.Available {
+				point = candidate
+			}
+		}
+		merged = append(merged, point)
+	}
+	return merged
+}
+
+// at is the projected quantity on date, with the movements of that exact day.
+func (p atpProjection) at(date string) AtpPoint {
+	at := AtpPoint{Date: date, Available: p.OnHand}
+	for _, point := range p.Points {
+		if point.Date > date {
+			break
+		}
+		if point.Date == date {
+			return point
+		}
+		at.Available = point.Available
+	}
+	return at
+}
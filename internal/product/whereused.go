@@ -0,0 +1,154 @@
+package product
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"sql-service/pkg/apperr"
+	"sql-service/pkg/db"
+)
+
+type bomParentLine struct {
+	Father         string
+	FatherName     *string
+	TreeType       string
+	FatherQuantity float64
+	Code           string
+	Quantity       float64
+}
+
+// GetBomParents returns the ITT1 lines using any of codes, with the OITT
+// header of their father.
+func (r *ProductRepository) GetBomParents(ctx context.Context, codes []string) ([]bomParentLine, error) {
+	if len(codes) == 0 {
+		return []bomParentLine{}, nil
+	}
+
+	list, args := namedList("code", codes)
+	rows, err := r.Db.QueryContext(ctx, fmt.Sprintf(`
+SELECT L.Father, H.Name, H.TreeType, H.Qauntity, L.Code, L.Quantity
+FROM ITT1 AS L WITH (NOLOCK)
+INNER JOIN OITT AS H WITH (NOLOCK)
+    ON H.Code = L.Father
+WHERE L.Code IN (%s)
+ORDER BY L.Code, L.Father`, list), args...)
+	if err != nil {
+		return nil, apperr.Upstream("where used query failed", err)
+	}
+	defer rows.Close()
+
+	result := make([]bomParentLine, 0)
+	for rows.Next() {
+		var (
+			line           bomParentLine
+			name           sql.NullString
+			fatherQuantity sql.NullFloat64
+			quantity       sql.NullFloat64
+		)
+		if err := rows.Scan(&line.Father, &name, &line.TreeType, &fatherQuantity, &line.Code, &quantity); err != nil {
+			return nil, apperr.Upstream("where used query failed", err)
+		}
+		line.FatherName = db.StringPtr(name)
+		line.FatherQuantity = fatherQuantity.Float64
+		line.Quantity = quantity.Float64
+		result = append(result, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, apperr.Upstream("where used query failed", err)
+	}
+	return result, nil
+}
+
+// WhereUsed walks ITT1 upwards from every code to the trees using it, level by
+// level up to dto.Depth (maxBomDepth by default). A father already on the path
+// is reported as a cycle and not followed.
+func (service *ProductService) WhereUsed(ctx context.Context, dto *WhereUsedDto) ([]WhereUsed, error) {
+	start := time.Now()
+	log.Printf("WhereUsed: start, codes=%d, depth=%d", len(dto.Codes), dto.Depth)
+
+	codes := dedupeSkus(dto.Codes)
+	if len(codes) == 0 {
+		return nil, apperr.Validation("code list cannot be empty", "codes must contain at least one item code")
+	}
+	depth := dto.Depth
+	if depth <= 0 {
+		depth = maxBomDepth
+	}
+	if depth > maxBomDepth {
+		return nil, apperr.Validation("depth is too large", fmt.Sprintf("depth must be between 1 and %d", maxBomDepth))
+	}
+
+	// upper cased component -> lines using it; a queried code without
+	// lines is a top level product
+	parents := map[string][]bomParentLine{}
+	queried := map[string]bool{}
+	frontier := codes
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		for _, code := range frontier {
+			queried[strings.ToUpper(code)] = true
+		}
+		lines, err := runSkuChunks(ctx, frontier, service.productRepository.GetBomParents)
+		if err != nil {
+			log.Printf("WhereUsed: error after %s: %v", time.Since(start), err)
+			return nil, err
+		}
+
+		next := make([]string, 0)
+		for _, line := range lines {
+			key := strings.ToUpper(line.Code)
+			parents[key] = append(parents[key], line)
+			if father := strings.ToUpper(line.Father); !queried[father] {
+				queried[father] = true
+				next = append(next, line.Father)
+			}
+		}
+		frontier = next
+	}
+
+	result := make([]WhereUsed, 0, len(codes))
+	for _, code := range codes {
+		entry := WhereUsed{Code: code, Parents: []BomUsage{}}
+		walkWhereUsed(parents, queried, []string{code}, nil, 1, depth, &entry.Parents)
+		result = append(result, entry)
+	}
+
+	log.Printf("WhereUsed: success, codes=%d, elapsed=%s", len(result), time.Since(start))
+	return result, nil
+}
+
+func walkWhereUsed(parents map[string][]bomParentLine, queried map[string]bool, path []string, quantities []float64, level, depth int, out *[]BomUsage) {
+	component := path[len(path)-1]
+	for _, line := range parents[strings.ToUpper(component)] {
+		perUnit := line.Quantity
+		if line.FatherQuantity > 0 {
+			perUnit = line.Quantity / line.FatherQuantity
+		}
+
+		usage := BomUsage{
+			Parent:     line.Father,
+			ParentName: line.FatherName,
+			TreeType:   line.TreeType,
+			Level:      level,
+			Path:       append(slices.Clone(path), line.Father),
+			Quantities: append(slices.Clone(quantities), perUnit),
+			Quantity:   perUnit,
+		}
+		for _, quantity := range quantities {
+			usage.Quantity *= quantity
+		}
+
+		father := strings.ToUpper(line.Father)
+		usage.Cycle = slices.ContainsFunc(path, func(code string) bool { return strings.ToUpper(code) == father })
+		usage.TopLevel = !usage.Cycle && queried[father] && len(parents[father]) == 0
+		*out = append(*out, usage)
+
+		if !usage.Cycle && level < depth {
+			walkWhereUsed(parents, queried, usage.Path, usage.Quantities, level+1, depth, out)
+		}
+	}
+}
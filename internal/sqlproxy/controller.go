@@ -1,8 +1,10 @@
 package sqlproxy
 
 import (
+	"fmt"
 	"net/http"
 
+	"sql-service/pkg/apperr"
 	"sql-service/pkg/req"
 	"sql-service/pkg/res"
 )
@@ -28,20 +30,23 @@ func (c *Controller) Run() http.HandlerFunc {
 			return
 		}
 
+		if len(body.Targets) > 0 {
+			c.runMany(w, r, body)
+			return
+		}
+
 		if body.DBName == "" {
-			res.Json(w, map[string]any{"error": "dbName is required"}, http.StatusBadRequest)
+			res.Error(w, apperr.Invalid("dbName is required"))
 			return
 		}
 		if body.DB.Server == "" || body.DB.Database == "" || body.DB.User == "" {
-			res.Json(w, map[string]any{
-				"error": "db.server, db.database, db.user are required",
-			}, http.StatusBadRequest)
+			res.Error(w, apperr.Invalid("db.server, db.database, db.user are required"))
 			return
 		}
 
 		out, err := c.Service.Run(r.Context(), body)
 		if err != nil {
-			res.Json(w, map[string]any{"error": err.Error()}, http.StatusBadRequest)
+			res.Error(w, err)
 			return
 		}
 
@@ -49,3 +54,30 @@ func (c *Controller) Run() http.HandlerFunc {
 		res.Json(w, Flatten(out), http.StatusOK)
 	}
 }
+
+func (c *Controller) runMany(w http.ResponseWriter, r *http.Request, body *QueryRequest) {
+	seen := make(map[string]bool, len(body.Targets))
+	for i, target := range body.Targets {
+		if target.DBName == "" {
+			res.Error(w, apperr.Validation("invalid targets", fmt.Sprintf("targets[%d].dbName is required", i)))
+			return
+		}
+		if seen[target.DBName] {
+			res.Error(w, apperr.Validation("invalid targets", fmt.Sprintf("duplicate target dbName %q", target.DBName)))
+			return
+		}
+		seen[target.DBName] = true
+		if target.DB.Server == "" || target.DB.Database == "" || target.DB.User == "" {
+			res.Error(w, apperr.Validation("invalid targets", fmt.Sprintf("targets[%d].db.server, db.database, db.user are required", i)))
+			return
+		}
+	}
+
+	out, err := c.Service.RunMany(r.Context(), body)
+	if err != nil {
+		res.Error(w, err)
+		return
+	}
+
+	res.Json(w, out, http.StatusOK)
+}
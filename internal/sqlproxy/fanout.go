@@ -0,0 +1,139 @@
+package sqlproxy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"sql-service/pkg/apperr"
+)
+
+const (
+	defaultFanOutConcurrency = 4
+	maxFanOutConcurrency     = 16
+
+	// fanOutDBNameColumn is added to every merged row to tell the databases apart.
+	fanOutDBNameColumn = "dbName"
+)
+
+// RunMany executes the same query against every target in parallel.
+// A failing database is reported in Databases and does not fail the request.
+func (s *Service) RunMany(ctx context.Context, req *QueryRequest) (*FanOutResponse, error) {
+	if req == nil {
+		return nil, fmt.Errorf("request is nil")
+	}
+	if len(req.Targets) == 0 {
+		return nil, fmt.Errorf("targets are required")
+	}
+	if err := ValidateQueryReadOnly(req.Query); err != nil {
+		return nil, apperr.Validation("invalid query", err.Error())
+	}
+
+	timeout := requestTimeout(req)
+	concurrency := fanOutConcurrency(req.MaxConcurrency, len(req.Targets))
+	sem := make(chan struct{}, concurrency)
+
+	outs := make([]*QueryResponse, len(req.Targets))
+	results := make([]DatabaseResult, len(req.Targets))
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i, target := range req.Targets {
+		wg.Add(1)
+		go func(i int, target QueryTarget) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			// the timeout starts once the target gets a slot, time spent
+			// queued behind other databases does not count against it
+			cctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			single := *req
+			single.DBName = target.DBName
+			single.DB = target.DB
+			single.Targets = nil
+
+			dbStart := time.Now()
+			out, err := s.repo.Query(cctx, &single)
+			result := DatabaseResult{
+				DBName:     target.DBName,
+				DurationMs: time.Since(dbStart).Milliseconds(),
+			}
+			switch {
+			case err != nil:
+				result.Error = err.Error()
+			case hasColumn(out, fanOutDBNameColumn):
+				result.Error = fmt.Sprintf("query returns a %s column, which is added to every fan-out row; alias it", fanOutDBNameColumn)
+			default:
+				result.Success = true
+				result.RowsTotal = out.RowsTotal
+				outs[i] = out
+			}
+			results[i] = result
+		}(i, target)
+	}
+	wg.Wait()
+
+	return mergeFanOut(outs, results, time.Since(start).Milliseconds()), nil
+}
+
+func fanOutConcurrency(requested, targets int) int {
+	concurrency := requested
+	if concurrency <= 0 {
+		concurrency = defaultFanOutConcurrency
+	}
+	if concurrency > maxFanOutConcurrency {
+		concurrency = maxFanOutConcurrency
+	}
+	if concurrency > targets {
+		concurrency = targets
+	}
+	return concurrency
+}
+
+// hasColumn reports whether the first result set, the one merged, has column.
+func hasColumn(out *QueryResponse, column string) bool {
+	if len(out.ResultSets) == 0 {
+		return false
+	}
+	for _, name := range out.ResultSets[0].Columns {
+		if name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// rows are merged in target order with a dbName column injected into each row
+func mergeFanOut(outs []*QueryResponse, results []DatabaseResult, durationMs int64) *FanOutResponse {
+	resp := &FanOutResponse{
+		DurationMs: durationMs,
+		Rows:       make([]map[string]any, 0),
+		Databases:  results,
+	}
+
+	multipleSets := false
+	for i, out := range outs {
+		if out == nil {
+			continue
+		}
+		flat := Flatten(out)
+		if len(out.ResultSets) > 1 {
+			multipleSets = true
+		}
+		for _, row := range flat.Rows {
+			row[fanOutDBNameColumn] = results[i].DBName
+			resp.Rows = append(resp.Rows, row)
+		}
+	}
+	resp.RowsTotal = len(resp.Rows)
+
+	if multipleSets {
+		resp.WarningNote = "query returned multiple result sets; only the first result set is returned in 'rows'"
+	}
+	return resp
+}
@@ -7,12 +7,21 @@ type DBConnDTO struct {
 	Password string `json:"password"`
 }
 
+type QueryTarget struct {
+	DBName string    `json:"dbName"`
+	DB     DBConnDTO `json:"db"`
+}
+
 type QueryRequest struct {
 	DBName    string         `json:"dbName"`
 	DB        DBConnDTO      `json:"db"`
 	Query     string         `json:"query"`
 	Params    map[string]any `json:"params"`
 	TimeoutMs int            `json:"timeoutMs,omitempty"`
+
+	// fan-out: when targets are set the query runs against every target
+	Targets        []QueryTarget `json:"targets,omitempty"`
+	MaxConcurrency int           `json:"maxConcurrency,omitempty"`
 }
 
 type ResultSet struct {
@@ -36,3 +45,20 @@ type FlatQueryResponse struct {
 	Rows        []map[string]any `json:"rows"`
 	WarningNote string           `json:"warningNote,omitempty"`
 }
+
+type DatabaseResult struct {
+	DBName     string `json:"dbName"`
+	Success    bool   `json:"success"`
+	DurationMs int64  `json:"durationMs"`
+	RowsTotal  int    `json:"rowsTotal"`
+	Error      string `json:"error,omitempty"`
+}
+
+// merged response for fan-out requests, every row carries its dbName
+type FanOutResponse struct {
+	DurationMs  int64            `json:"durationMs"`
+	RowsTotal   int              `json:"rowsTotal"`
+	Rows        []map[string]any `json:"rows"`
+	Databases   []DatabaseResult `json:"databases"`
+	WarningNote string           `json:"warningNote,omitempty"`
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"time"
+
+	"sql-service/pkg/apperr"
 )
 
 type Service struct {
@@ -19,16 +21,23 @@ func (s *Service) Run(ctx context.Context, req *QueryRequest) (*QueryResponse, e
 		return nil, fmt.Errorf("request is nil")
 	}
 	if err := ValidateQueryReadOnly(req.Query); err != nil {
-		return nil, err
+		return nil, apperr.Validation("invalid query", err.Error())
 	}
 
+	cctx, cancel := context.WithTimeout(ctx, requestTimeout(req))
+	defer cancel()
+
+	out, err := s.repo.Query(cctx, req)
+	if err != nil {
+		return nil, apperr.Upstream("query failed", err)
+	}
+	return out, nil
+}
+
+func requestTimeout(req *QueryRequest) time.Duration {
 	timeout := 30 * time.Second
 	if req.TimeoutMs > 0 && req.TimeoutMs < 120000 {
 		timeout = time.Duration(req.TimeoutMs) * time.Millisecond
 	}
-
-	cctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
-
-	return s.repo.Query(cctx, req)
+	return timeout
 }
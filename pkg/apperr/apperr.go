@@ -0,0 +1,98 @@
+package apperr
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+type Kind string
+
+const (
+	KindValidation Kind = "validation"
+	KindNotFound   Kind = "not_found"
+	KindUpstream   Kind = "upstream"
+	KindTimeout    Kind = "timeout"
+	KindInternal   Kind = "internal"
+)
+
+// Error is the error type carried from repositories through services to controllers.
+type Error struct {
+	Kind    Kind
+	Message string
+	Details string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+func Validation(message, details string) *Error {
+	return &Error{Kind: KindValidation, Message: message, Details: details}
+}
+
+// Invalid is a validation error whose message says all there is to say; like
+// NotFound it is repeated as the details.
+func Invalid(message string) *Error {
+	return &Error{Kind: KindValidation, Message: message, Details: message}
+}
+
+func NotFound(message string) *Error {
+	return &Error{Kind: KindNotFound, Message: message, Details: message}
+}
+
+func Internal(message string, err error) error {
+	return wrap(KindInternal, message, err)
+}
+
+// Upstream wraps a database failure, deadline errors are reported as timeouts.
+func Upstream(message string, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return wrap(KindTimeout, message, err)
+	}
+	return wrap(KindUpstream, message, err)
+}
+
+func wrap(kind Kind, message string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return err
+	}
+	return &Error{Kind: kind, Message: message, Details: err.Error(), Err: err}
+}
+
+// From returns the *Error inside err, anything else is treated as internal.
+func From(err error) *Error {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &Error{Kind: KindTimeout, Message: "request timed out", Details: err.Error(), Err: err}
+	}
+	return &Error{Kind: KindInternal, Message: "internal error", Details: err.Error(), Err: err}
+}
+
+func Status(kind Kind) int {
+	switch kind {
+	case KindValidation:
+		return http.StatusBadRequest
+	case KindNotFound:
+		return http.StatusNotFound
+	case KindUpstream:
+		return http.StatusBadGateway
+	case KindTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
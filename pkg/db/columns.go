@@ -0,0 +1,66 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TableColumns returns the column names of table in ordinal order. The schema
+// is read once per table and cached for the lifetime of the connection.
+func (db *Db) TableColumns(ctx context.Context, table string) ([]string, error) {
+	key := strings.ToUpper(table)
+
+	db.columnsMu.Lock()
+	cached, ok := db.columns[key]
+	db.columnsMu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	var (
+		query string
+		args  []any
+	)
+	switch strings.ToLower(db.Dialect) {
+	case "", "mssql":
+		query = "SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_NAME = @table ORDER BY ORDINAL_POSITION"
+		args = []any{sql.Named("table", table)}
+	case "hana":
+		query = "SELECT COLUMN_NAME FROM SYS.TABLE_COLUMNS WHERE SCHEMA_NAME = CURRENT_SCHEMA AND TABLE_NAME = ? ORDER BY POSITION"
+		args = []any{table}
+	default:
+		return nil, fmt.Errorf("unsupported db dialect: %s", db.Dialect)
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make([]string, 0)
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s not found", table)
+	}
+
+	db.columnsMu.Lock()
+	if db.columns == nil {
+		db.columns = make(map[string][]string)
+	}
+	db.columns[key] = columns
+	db.columnsMu.Unlock()
+
+	return columns, nil
+}
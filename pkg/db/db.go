@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"sync"
 
 	"sql-service/configs"
 
@@ -14,6 +15,9 @@ import (
 type Db struct {
 	*sql.DB
 	Dialect string
+
+	columnsMu sync.Mutex
+	columns   map[string][]string
 }
 
 func NewConnection(cfg *configs.Config) (*Db, error) {
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// StringPtr, IntPtr, FloatPtr and TimePtr turn a scanned nullable column into
+// a pointer that is nil for NULL.
+
+func StringPtr(v sql.NullString) *string {
+	if !v.Valid {
+		return nil
+	}
+	return &v.String
+}
+
+func IntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+func FloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+func TimePtr(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
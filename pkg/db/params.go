@@ -0,0 +1,113 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Filter is one condition of a query with {p} standing for its parameter.
+type Filter struct {
+	Name   string
+	Value  any
+	Clause string
+}
+
+// Param renders the {p} tokens of clause for one parameter. MSSQL refers to it
+// by name, bound once with sql.Named; HANA gets a ? and a copy of the value for
+// every {p}.
+func Param(dialect, clause, name string, value any) (string, []any, error) {
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+		return strings.ReplaceAll(clause, "{p}", "@"+name), []any{sql.Named(name, value)}, nil
+	case "hana":
+		args := make([]any, 0, 1)
+		for i := strings.Count(clause, "{p}"); i > 0; i-- {
+			args = append(args, value)
+		}
+		return strings.ReplaceAll(clause, "{p}", "?"), args, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+}
+
+// BindFilters renders the filters joined by AND, "1 = 1" when there are none.
+func BindFilters(dialect string, filters []Filter) (string, []any, error) {
+	conditions := make([]string, 0, len(filters))
+	args := make([]any, 0, len(filters))
+	for _, filter := range filters {
+		condition, filterArgs, err := Param(dialect, filter.Clause, filter.Name, filter.Value)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, filterArgs...)
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", args, nil
+	}
+	return strings.Join(conditions, "\n  AND "), args, nil
+}
+
+// Bind replaces {name} tokens with named parameters on MSSQL and positional
+// ones on HANA.
+func Bind(dialect, statement string, values map[string]any) (string, []any, error) {
+	switch strings.ToLower(dialect) {
+	case "", "mssql":
+		args := make([]any, 0, len(values))
+		for name, value := range values {
+			if !strings.Contains(statement, "{"+name+"}") {
+				continue
+			}
+			statement = strings.ReplaceAll(statement, "{"+name+"}", "@"+name)
+			args = append(args, sql.Named(name, value))
+		}
+		return statement, args, nil
+	case "hana":
+		statement, args := BindPositional(statement, values)
+		return statement, args, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported db dialect: %s", dialect)
+	}
+}
+
+// BindPositional replaces every {name} token with ? and returns the values in
+// the order the tokens appear.
+func BindPositional(clause string, values map[string]any) (string, []any) {
+	var (
+		out  strings.Builder
+		args []any
+	)
+	for {
+		start := strings.IndexByte(clause, '{')
+		if start == -1 {
+			out.WriteString(clause)
+			break
+		}
+		end := strings.IndexByte(clause[start:], '}')
+		if end == -1 {
+			out.WriteString(clause)
+			break
+		}
+		name := clause[start+1 : start+end]
+		out.WriteString(clause[:start])
+		out.WriteString("?")
+		args = append(args, values[name])
+		clause = clause[start+end+1:]
+	}
+	return out.String(), args
+}
+
+// EscapeLike escapes the LIKE wildcards of value for an ESCAPE '\' clause.
+func EscapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `[`, `\[`)
+	return replacer.Replace(value)
+}
+
+// QuoteIdentifier quotes a column name that was checked against the schema.
+func QuoteIdentifier(dialect, name string) string {
+	if strings.ToLower(dialect) == "hana" {
+		return `"` + name + `"`
+	}
+	return "[" + name + "]"
+}
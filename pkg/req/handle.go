@@ -2,13 +2,14 @@ package req
 
 import (
 	"net/http"
+	"sql-service/pkg/apperr"
 	"sql-service/pkg/res"
 )
 
 func HandleBody[T any](w *http.ResponseWriter, r *http.Request) (*T, error) {
 	body, err := Decode[T](r.Body)
 	if err != nil {
-		res.Json(*w, err.Error(), 402)
+		res.Error(*w, apperr.Validation("invalid JSON body", err.Error()))
 		return nil, err
 	}
 	return &body, nil
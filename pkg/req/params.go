@@ -0,0 +1,32 @@
+package req
+
+import (
+	"strconv"
+	"strings"
+
+	"sql-service/pkg/apperr"
+)
+
+// OptionalInt parses the query parameter name, nil when it is empty.
+func OptionalInt(value, name string) (*int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, apperr.Validation("invalid "+name, name+" must be an integer")
+	}
+	return &parsed, nil
+}
+
+// IsIdentifier reports whether value is a plain column name that may be
+// quoted into a query.
+func IsIdentifier(value string) bool {
+	for _, r := range value {
+		if !(r == '_' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return false
+		}
+	}
+	return value != ""
+}
@@ -2,7 +2,10 @@ package res
 
 import (
 	"encoding/json"
+	"log"
 	"net/http"
+
+	"sql-service/pkg/apperr"
 )
 
 func Json(w http.ResponseWriter, data any, statusCode int) {
@@ -10,3 +13,32 @@ func Json(w http.ResponseWriter, data any, statusCode int) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(data)
 }
+
+type ErrorBody struct {
+	Error   string `json:"error"`
+	Code    string `json:"code"`
+	Details string `json:"details,omitempty"`
+}
+
+// serverErrorDetails replaces the details of errors that carry driver or
+// runtime text, which stays in the server log instead of the response.
+var serverErrorDetails = map[apperr.Kind]string{
+	apperr.KindUpstream: "the database request failed",
+	apperr.KindTimeout:  "the database did not answer in time",
+	apperr.KindInternal: "an unexpected error occurred",
+}
+
+// Error writes the JSON error envelope with the status mapped from the error kind.
+func Error(w http.ResponseWriter, err error) {
+	appErr := apperr.From(err)
+	details := appErr.Details
+	if generic, ok := serverErrorDetails[appErr.Kind]; ok {
+		log.Printf("[res] %s error: %v", appErr.Kind, appErr)
+		details = generic
+	}
+	Json(w, ErrorBody{
+		Error:   appErr.Message,
+		Code:    string(appErr.Kind),
+		Details: details,
+	}, apperr.Status(appErr.Kind))
+}